@@ -5,6 +5,8 @@ import (
 	"github.com/BurntSushi/toml"
 	"github.com/psihachina/go-test-work.git/internal/app/apiserver"
 	"log"
+	"os"
+	"strings"
 )
 
 var (
@@ -24,7 +26,15 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if err := apiserver.Start(config); err != nil {
+	switch {
+	case flag.Arg(0) == "audit" && flag.Arg(1) == "verify":
+		err = apiserver.VerifyAudit(config, os.Stdout)
+	case flag.NArg() == 0:
+		err = apiserver.Start(config)
+	default:
+		log.Fatalf("unknown command %q, usage: apiserver [-config-path path] [audit verify]", strings.Join(flag.Args(), " "))
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
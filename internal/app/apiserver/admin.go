@@ -0,0 +1,172 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+var errInvalidPagination = errors.New("offset and limit must be non-negative integers")
+
+// HandleAdminUsersList lists users page by page. The q query parameter
+// searches by the beginning of the username or the email, status filters by
+// account status.
+func (s *server) HandleAdminUsersList(c *gin.Context) {
+	offset, limit, ok := s.pagination(c)
+	if !ok {
+		return
+	}
+
+	// One more user than asked for tells whether there is a next page.
+	users, err := s.store.User().List(&model.UserFilter{
+		Query:  c.Query("q"),
+		Status: c.Query("status"),
+		Offset: offset,
+		Limit:  limit + 1,
+	})
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := map[string]interface{}{
+		"offset": offset,
+		"limit":  limit,
+	}
+	if len(users) > limit {
+		users = users[:limit]
+		res["next_offset"] = offset + limit
+	}
+	if users == nil {
+		users = []*model.User{}
+	}
+	res["users"] = users
+
+	s.respond(c.Writer, c.Request, http.StatusOK, res)
+}
+
+// HandleAdminUsersGet ...
+func (s *server) HandleAdminUsersGet(c *gin.Context) {
+	u, ok := s.adminFindUser(c)
+	if !ok {
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, u)
+}
+
+// HandleAdminSessionsList lists the refresh sessions of a user.
+func (s *server) HandleAdminSessionsList(c *gin.Context) {
+	u, ok := s.adminFindUser(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := s.store.Token().FindByUser(u.ID.Hex())
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+	if sessions == nil {
+		sessions = []*model.Session{}
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// HandleAdminUsersDisable ...
+func (s *server) HandleAdminUsersDisable(c *gin.Context) {
+	s.adminSetStatus(c, model.UserStatusDisabled)
+}
+
+// HandleAdminUsersEnable ...
+func (s *server) HandleAdminUsersEnable(c *gin.Context) {
+	s.adminSetStatus(c, model.UserStatusActive)
+}
+
+// HandleAdminSessionsDelete logs a user out of every session.
+func (s *server) HandleAdminSessionsDelete(c *gin.Context) {
+	u, ok := s.adminFindUser(c)
+	if !ok {
+		return
+	}
+
+	if err := s.store.Token().DeleteTokens(&model.AccessDetails{UserID: u.ID.Hex()}); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, "Successfully logged out")
+}
+
+func (s *server) adminSetStatus(c *gin.Context, status string) {
+	u, ok := s.adminFindUser(c)
+	if !ok {
+		return
+	}
+
+	u.Status = status
+	if err := s.store.User().UpdateStatus(u.ID.Hex(), status); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Access tokens run out on their own; without refresh sessions a
+	// disabled user cannot get new ones.
+	if status == model.UserStatusDisabled {
+		if err := s.store.Token().DeleteTokens(&model.AccessDetails{UserID: u.ID.Hex()}); err != nil {
+			s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, u)
+}
+
+// pagination reads the offset and limit query parameters. On failure it
+// has already responded.
+func (s *server) pagination(c *gin.Context) (int, int, bool) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, errInvalidPagination)
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageLimit)))
+	if err != nil || limit <= 0 {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, errInvalidPagination)
+		return 0, 0, false
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	return offset, limit, true
+}
+
+// adminFindUser finds the user named by the id path parameter. On failure
+// it has already responded.
+func (s *server) adminFindUser(c *gin.Context) (*model.User, bool) {
+	u, err := s.store.User().Find(c.Param("id"))
+	if err == store.ErrRecordNotFound {
+		s.error(c.Writer, c.Request, http.StatusNotFound, errUnknownUser)
+		return nil, false
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	auditSubject(c, u.ID.Hex())
+
+	return u, true
+}
@@ -0,0 +1,77 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_AdminAPI(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+
+	admin := model.TestUser(t)
+	admin.Email = "admin@example.org"
+	admin.Username = "admin"
+	admin.Scopes = []string{model.ScopeAdmin}
+	adminTokens, _ := testLoginUser(t, s, admin)
+
+	u := model.TestUser(t)
+	u.Username = "user"
+	userTokens, _ := testLoginUser(t, s, u)
+	testLoginUser(t, s, &model.User{Email: "other@example.org", Username: "other", EmailVerified: true, Password: "password"})
+
+	get := func(path string, accessToken string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/admin/users", userTokens["access_token"])
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = get("/admin/users?limit=2", adminTokens["access_token"])
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page := struct {
+		Users      []*model.User `json:"users"`
+		NextOffset int           `json:"next_offset"`
+	}{}
+	_ = json.NewDecoder(rec.Body).Decode(&page)
+	if assert.Len(t, page.Users, 2) {
+		assert.Equal(t, "admin", page.Users[0].Username)
+	}
+	assert.Equal(t, 2, page.NextOffset)
+
+	rec = get("/admin/users?q=us", adminTokens["access_token"])
+	assert.Contains(t, rec.Body.String(), u.Email)
+	assert.NotContains(t, rec.Body.String(), "other@example.org")
+
+	rec = get("/admin/users?limit=-1", adminTokens["access_token"])
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = get("/admin/users/"+u.ID.Hex()+"/sessions", adminTokens["access_token"])
+	assert.Equal(t, http.StatusOK, rec.Code)
+	sessions := map[string][]*model.Session{}
+	_ = json.NewDecoder(rec.Body).Decode(&sessions)
+	assert.Len(t, sessions["sessions"], 1)
+
+	rec = testJSONRequest(s, "/admin/users/"+u.ID.Hex()+"/disable", adminTokens["access_token"], nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), model.UserStatusDisabled)
+	rec = testJSONRequest(s, "/admin/users/"+u.ID.Hex()+"/enable", adminTokens["access_token"], nil)
+	assert.Contains(t, rec.Body.String(), model.UserStatusActive)
+
+	rec = testJSONRequest(s, "/admin/users/"+u.ID.Hex()+"/logout", adminTokens["access_token"], nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = get("/admin/users/"+u.ID.Hex()+"/sessions", adminTokens["access_token"])
+	assert.Contains(t, rec.Body.String(), `"sessions":[]`)
+
+	rec = get("/admin/users/000000000000000000000000", adminTokens["access_token"])
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -5,32 +5,62 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
+	"github.com/go-redis/redis/v7"
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
 	"github.com/psihachina/go-test-work.git/internal/app/store/mongodbstore"
+	"github.com/psihachina/go-test-work.git/internal/app/store/redisstore"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Start ...
 func Start(config *Config) error {
-	db, err := newDB(config.DatabaseURL)
+	if err := config.Tokens.validate(); err != nil {
+		return err
+	}
+	if err := config.OIDC.validate(); err != nil {
+		return err
+	}
+
+	store, closeStore, err := newStore(config)
 	if err != nil {
 		return err
 	}
 
-	defer db.Client().Disconnect(context.Background())
+	defer closeStore()
 
-	store := mongodbstore.New(db)
+	mailer, err := newMailer(&config.Mailer)
+	if err != nil {
+		return err
+	}
 
-	srv := newServer(store)
+	srv := newServer(store, mailer, config)
+	if _, _, err := srv.signingKey.load(); err != nil {
+		return fmt.Errorf("oidc signing key: %v", err)
+	}
+	if config.OIDC.SigningKeyFile == "" {
+		srv.logger.Warn("oidc.generate_signing_key is set: signed tokens stop verifying after a restart")
+	}
+	go srv.runWebhooks(nil)
+
+	return http.ListenAndServe(listenAddr(config.BindAddr, os.Getenv("PORT")), srv)
+}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		fmt.Errorf("$PORT not set")
+// listenAddr returns the address to listen on. A platform that assigns the
+// port, such as Heroku with the Procfile, sets $PORT and it wins over
+// bind_addr; without it the configured bind_addr is used.
+func listenAddr(bindAddr string, port string) string {
+	if port != "" {
+		return ":" + port
 	}
-	return http.ListenAndServe(":"+port, srv)
+
+	return bindAddr
 }
 
 func newDB(databaseURL string) (*mongo.Database, error) {
@@ -39,7 +69,8 @@ func newDB(databaseURL string) (*mongo.Database, error) {
 		return nil, err
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	err = db.Connect(ctx)
 	if err != nil {
@@ -48,3 +79,108 @@ func newDB(databaseURL string) (*mongo.Database, error) {
 
 	return db.Database("test_work"), nil
 }
+
+// newStore opens the store DatabaseURL points at, PostgreSQL for a
+// postgres:// URL, an embedded SQLite file for a file:// URL and Mongo
+// otherwise, and returns it with a function that closes it. Refresh
+// sessions are kept in Redis when the token store driver asks for it.
+func newStore(config *Config) (store.Store, func(), error) {
+	var base store.Store
+	var closeStore func()
+	switch databaseScheme(config.DatabaseURL) {
+	case "postgres", "postgresql", "file":
+		db, err := sqlstore.Open(config.DatabaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		sqlStore := sqlstore.New(db)
+		stop := make(chan struct{})
+		go sweepExpired(sqlStore, config.SweepInterval.Duration, stop)
+		base = sqlStore
+		closeStore = func() {
+			close(stop)
+			db.Close()
+		}
+	default:
+		db, err := newDB(config.DatabaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		base = mongodbstore.New(db)
+		closeStore = func() { db.Client().Disconnect(context.Background()) }
+	}
+
+	switch config.TokenStore.Driver {
+	case "database", "mongodb", "":
+		return base, closeStore, nil
+	case "redis":
+		opts, err := redis.ParseURL(config.TokenStore.URL)
+		if err != nil {
+			closeStore()
+			return nil, nil, err
+		}
+
+		client := redis.NewClient(opts)
+		if err := client.Ping().Err(); err != nil {
+			closeStore()
+			return nil, nil, err
+		}
+		return redisstore.New(client, base), func() {
+			client.Close()
+			closeStore()
+		}, nil
+	default:
+		closeStore()
+		return nil, nil, fmt.Errorf("unknown token store driver: %s", config.TokenStore.Driver)
+	}
+}
+
+// sweepExpired deletes expired records of the SQL store every interval
+// until stop is closed; SQL databases have no TTL indexes to do it.
+func sweepExpired(s *sqlstore.Store, interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if _, err := s.Sweep(now); err != nil {
+				log.Printf("sweep expired records: %v", err)
+			}
+		}
+	}
+}
+
+func databaseScheme(databaseURL string) string {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Scheme
+}
+
+func newMailer(config *MailerConfig) (mailer.Mailer, error) {
+	switch config.Driver {
+	case "smtp":
+		return mailer.NewSMTP(config.Addr, config.Username, config.Password, config.From), nil
+	case "log", "":
+		if config.File == "" {
+			return mailer.NewLog(os.Stderr), nil
+		}
+
+		f, err := os.OpenFile(config.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return mailer.NewLog(f), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver: %s", config.Driver)
+	}
+}
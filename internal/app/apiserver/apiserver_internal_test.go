@@ -0,0 +1,26 @@
+package apiserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenAddr(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bindAddr string
+		port     string
+		expected string
+	}{
+		{name: "bind_addr", bindAddr: ":8080", port: "", expected: ":8080"},
+		{name: "$PORT", bindAddr: ":8080", port: "5000", expected: ":5000"},
+		{name: "$PORT without bind_addr", bindAddr: "", port: "5000", expected: ":5000"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, listenAddr(tc.bindAddr, tc.port))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+const (
+	ctxKeyAuditSubject = "audit_subject"
+	ctxKeyAuditSession = "audit_session"
+	ctxKeyAuditReason  = "audit_reason"
+
+	maxAuditBody = 1024
+
+	// auditRefreshReused is the reason of a refresh with a token whose
+	// session is already gone, most likely because the token was stolen.
+	auditRefreshReused = "refresh_token_reused"
+)
+
+// auditWriter keeps the beginning of error responses, where the reason of
+// a failure is found.
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.Status() >= http.StatusBadRequest && w.body.Len() < maxAuditBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// audit records an event of eventType once the handler has run. The
+// outcome follows the response status; handlers name the user acted on
+// with auditSubject and the session with auditSession.
+func (s *server) audit(eventType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		c.Next()
+
+		e := &model.AuditEvent{
+			Type:      eventType,
+			Outcome:   model.AuditSuccess,
+			Subject:   c.GetString(ctxKeyAuditSubject),
+			SessionID: c.GetString(ctxKeyAuditSession),
+			Reason:    c.GetString(ctxKeyAuditReason),
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Time:      time.Now().UTC(),
+		}
+		if v, ok := c.Get(ctxKeyAccessDetails); ok {
+			details := v.(*model.AccessDetails)
+			e.Actor = details.UserID
+			if details.Impersonated() {
+				e.Actor = details.ActorID
+			}
+		}
+		if e.Actor == "" {
+			e.Actor = e.Subject
+		}
+		if w.Status() >= http.StatusBadRequest {
+			e.Outcome = model.AuditFailure
+			if e.Reason == "" {
+				e.Reason = auditReason(w.Status(), w.body.Bytes())
+			}
+		}
+
+		s.recordAudit(e)
+	}
+}
+
+func (s *server) recordAudit(e *model.AuditEvent) {
+	defer s.enqueueWebhooks(e)
+
+	if err := s.auditChain.append(s.store.Audit(), e); err != nil {
+		s.logger.WithField("event", e).Errorf("audit: %v", err)
+		return
+	}
+
+	if every := s.config.Audit.CheckpointEvery; every > 0 && e.Seq%every == 0 {
+		if err := s.writeAuditCheckpoint(e); err != nil {
+			s.logger.Errorf("audit checkpoint at seq %d: %v", e.Seq, err)
+		}
+	}
+}
+
+// auditReason takes the reason of a failure from the error response: the
+// error code when there is one, the error message otherwise.
+func auditReason(status int, body []byte) string {
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(body, &res); err == nil {
+		for _, key := range []string{"code", "error"} {
+			if v, ok := res[key].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+
+	var message string
+	if err := json.Unmarshal(body, &message); err == nil && message != "" {
+		return message
+	}
+
+	return http.StatusText(status)
+}
+
+func auditSubject(c *gin.Context, userID string) {
+	c.Set(ctxKeyAuditSubject, userID)
+}
+
+func auditSession(c *gin.Context, sessionID string) {
+	c.Set(ctxKeyAuditSession, sessionID)
+}
+
+// HandleAdminAuditList lists audit events, newest first, filtered by the
+// user, type, from and to query parameters. Times are RFC 3339.
+func (s *server) HandleAdminAuditList(c *gin.Context) {
+	offset, limit, ok := s.pagination(c)
+	if !ok {
+		return
+	}
+
+	f := &model.AuditFilter{
+		UserID: c.Query("user"),
+		Type:   c.Query("type"),
+		Offset: offset,
+		Limit:  limit + 1,
+	}
+	for param, t := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := c.Query(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+				return
+			}
+			*t = parsed
+		}
+	}
+
+	events, err := s.store.Audit().Find(f)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := map[string]interface{}{
+		"offset": offset,
+		"limit":  limit,
+	}
+	if len(events) > limit {
+		events = events[:limit]
+		res["next_offset"] = offset + limit
+	}
+	if events == nil {
+		events = []*model.AuditEvent{}
+	}
+	res["events"] = events
+
+	s.respond(c.Writer, c.Request, http.StatusOK, res)
+}
@@ -0,0 +1,85 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Audit(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+	admin := model.TestUser(t)
+	admin.Email = "admin@example.org"
+	admin.Scopes = []string{model.ScopeAdmin}
+	adminTokens, _ := testLoginUser(t, s, admin)
+	u := &model.User{Email: "user@example.com", EmailVerified: true, Password: "password"}
+	tokens, _ := testLoginUser(t, s, u)
+
+	rec := testJSONRequest(s, "/Login", "", map[string]string{"email": u.Email, "password": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+	req.Header.Set(refreshTokenHeader, tokens["refresh_token"])
+	req.Header.Set("User-Agent", "audit-test")
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	testJSONRequest(s, "/admin/users/"+u.ID.Hex()+"/impersonate", adminTokens["access_token"], map[string]string{"reason": "ticket 42"})
+
+	list := func(query url.Values) []*model.AuditEvent {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/admin/audit?"+query.Encode(), nil)
+		req.Header.Set("Authorization", "Bearer "+adminTokens["access_token"])
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		res := struct {
+			Events []*model.AuditEvent `json:"events"`
+		}{}
+		_ = json.NewDecoder(rec.Body).Decode(&res)
+		return res.Events
+	}
+
+	events := list(url.Values{"user": {u.ID.Hex()}})
+	if assert.Len(t, events, 4) {
+		impersonate, refresh, failedLogin, login := events[0], events[1], events[2], events[3]
+
+		assert.Equal(t, model.AuditImpersonate, impersonate.Type)
+		assert.Equal(t, admin.ID.Hex(), impersonate.Actor)
+		assert.Equal(t, u.ID.Hex(), impersonate.Subject)
+		assert.Equal(t, "ticket 42", impersonate.Reason)
+
+		assert.Equal(t, model.AuditRefresh, refresh.Type)
+		assert.Equal(t, model.AuditSuccess, refresh.Outcome)
+		assert.Equal(t, "audit-test", refresh.UserAgent)
+		assert.NotEmpty(t, refresh.SessionID)
+
+		assert.Equal(t, model.AuditLogin, failedLogin.Type)
+		assert.Equal(t, model.AuditFailure, failedLogin.Outcome)
+		assert.Equal(t, "invalid_credentials", failedLogin.Reason)
+
+		assert.Equal(t, model.AuditLogin, login.Type)
+		assert.Equal(t, u.ID.Hex(), login.Actor)
+		assert.NotEmpty(t, login.SessionID)
+	}
+
+	events = list(url.Values{"type": {model.AuditLogin}})
+	assert.Len(t, events, 3)
+
+	events = list(url.Values{"from": {time.Now().Add(time.Minute).Format(time.RFC3339)}})
+	assert.Empty(t, events)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/admin/audit?from=yesterday", nil)
+	req.Header.Set("Authorization", "Bearer "+adminTokens["access_token"])
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
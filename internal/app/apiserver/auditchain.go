@@ -0,0 +1,212 @@
+package apiserver
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/twinj/uuid"
+)
+
+const (
+	auditCheckpointType = "audit-checkpoint+jwt"
+	auditAppendAttempts = 5
+)
+
+// auditChain links every new audit event to the previous one. Appends from
+// this instance are serialized; when another instance has appended in the
+// meantime the store reports a conflict on Seq and the head of the chain is
+// reloaded.
+type auditChain struct {
+	mu   sync.Mutex
+	head *model.AuditEvent
+}
+
+func (ch *auditChain) append(repo store.AuditRepository, e *model.AuditEvent) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	// Mongo keeps milliseconds and PostgreSQL microseconds; the hash must
+	// cover the time as it is read back.
+	e.Time = e.Time.Truncate(time.Millisecond)
+
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		if ch.head == nil {
+			head, err := repo.Last()
+			if err == store.ErrRecordNotFound {
+				head = &model.AuditEvent{}
+			} else if err != nil {
+				return err
+			}
+			ch.head = head
+		}
+
+		e.Seq = ch.head.Seq + 1
+		e.PrevHash = ch.head.Hash
+		e.Hash = e.ComputeHash()
+
+		err := repo.Create(e)
+		if err == store.ErrConflict {
+			ch.head = nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		ch.head = e
+		return nil
+	}
+
+	return store.ErrConflict
+}
+
+func (s *server) writeAuditCheckpoint(e *model.AuditEvent) error {
+	now := time.Now()
+	token, err := s.signingKey.sign(&model.AuditCheckpointClaims{
+		RegisteredClaims: s.registeredClaims("audit", uuid.NewV4().String(), now, 0),
+		Seq:              e.Seq,
+		Hash:             e.Hash,
+	}, auditCheckpointType)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Audit().CreateCheckpoint(&model.AuditCheckpoint{
+		Seq:   e.Seq,
+		Hash:  e.Hash,
+		Time:  now.UTC(),
+		Token: token,
+	})
+}
+
+// auditBreak is the first place where the audit log fails verification.
+type auditBreak struct {
+	Seq     int64
+	EventID string
+	Reason  string
+}
+
+func (b *auditBreak) Error() string {
+	if b.EventID == "" {
+		return fmt.Sprintf("audit log broken at seq %d: %s", b.Seq, b.Reason)
+	}
+	return fmt.Sprintf("audit log broken at seq %d (event %s): %s", b.Seq, b.EventID, b.Reason)
+}
+
+// auditReport sums up a verified audit log. Unchained events were written
+// before the hash chain was introduced.
+type auditReport struct {
+	Events      int
+	Unchained   int
+	Checkpoints int
+}
+
+// verifyAuditLog walks the hash chain and then checks the checkpoints
+// against it. Checkpoints are skipped when key is nil. It returns an
+// *auditBreak for the first broken link.
+func verifyAuditLog(repo store.AuditRepository, key *signingKey) (*auditReport, error) {
+	checkpoints, err := repo.FindCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	checkpointed := make(map[int64]string, len(checkpoints))
+	for _, c := range checkpoints {
+		checkpointed[c.Seq] = ""
+	}
+
+	// Unchained events sort first. They are only accepted when they were
+	// written before the first chained event, otherwise anyone able to
+	// write to the log could add events that no hash covers.
+	report := &auditReport{}
+	prev := &model.AuditEvent{}
+	var lastUnchained *model.AuditEvent
+	err = repo.Walk(func(e *model.AuditEvent) error {
+		id := e.ID.Hex()
+		switch {
+		case e.Seq == 0 && e.Hash == "" && prev.Seq == 0:
+			if lastUnchained == nil || e.Time.After(lastUnchained.Time) {
+				lastUnchained = e
+			}
+			report.Unchained++
+			return nil
+		case e.Seq == 0 && e.Hash == "":
+			return &auditBreak{Seq: prev.Seq, EventID: id, Reason: "unchained event after the start of the chain"}
+		case prev.Seq == 0 && lastUnchained != nil && lastUnchained.Time.Truncate(time.Millisecond).After(e.Time):
+			return &auditBreak{Seq: e.Seq, EventID: lastUnchained.ID.Hex(), Reason: "unchained event after the start of the chain"}
+		case e.Seq != prev.Seq+1:
+			return &auditBreak{Seq: prev.Seq + 1, Reason: fmt.Sprintf("event missing, next one has seq %d", e.Seq)}
+		case e.PrevHash != prev.Hash:
+			return &auditBreak{Seq: e.Seq, EventID: id, Reason: "previous hash does not match"}
+		case e.Hash != e.ComputeHash():
+			return &auditBreak{Seq: e.Seq, EventID: id, Reason: "event content does not match its hash"}
+		}
+
+		if _, ok := checkpointed[e.Seq]; ok {
+			checkpointed[e.Seq] = e.Hash
+		}
+		report.Events++
+		prev = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return report, nil
+	}
+	for _, c := range checkpoints {
+		claims := &model.AuditCheckpointClaims{}
+		if err := key.verify(c.Token, auditCheckpointType, claims); err != nil {
+			return nil, &auditBreak{Seq: c.Seq, Reason: "checkpoint signature: " + err.Error()}
+		}
+		if claims.Seq != c.Seq {
+			return nil, &auditBreak{Seq: c.Seq, Reason: "checkpoint signed for another seq"}
+		}
+		switch hash := checkpointed[c.Seq]; {
+		case hash == "":
+			return nil, &auditBreak{Seq: c.Seq, Reason: "checkpointed event missing"}
+		case hash != claims.Hash:
+			return nil, &auditBreak{Seq: c.Seq, Reason: "event hash differs from the signed checkpoint"}
+		}
+		report.Checkpoints++
+	}
+
+	return report, nil
+}
+
+// VerifyAudit verifies the audit log in the database and writes the result
+// to w. Checkpoints are only verified when the signing key is kept in a
+// file.
+func VerifyAudit(config *Config, w io.Writer) error {
+	store, closeStore, err := newStore(config)
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	var key *signingKey
+	if config.OIDC.SigningKeyFile != "" {
+		key = &signingKey{path: config.OIDC.SigningKeyFile}
+	} else {
+		fmt.Fprintln(w, "oidc.signing_key_file is not set, skipping checkpoints")
+	}
+
+	report, err := verifyAuditLog(store.Audit(), key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "audit log intact: %d events, %d checkpoints verified", report.Events, report.Checkpoints)
+	if report.Unchained > 0 {
+		fmt.Fprintf(w, ", %d events predate the hash chain", report.Unchained)
+	}
+	fmt.Fprintln(w)
+
+	return nil
+}
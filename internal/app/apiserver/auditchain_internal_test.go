@@ -0,0 +1,133 @@
+package apiserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAuditLog(t *testing.T) *server {
+	t.Helper()
+
+	config := NewConfig()
+	config.Audit.CheckpointEvery = 2
+	s := newServer(teststore.New(), &testMailer{}, config)
+
+	// An event written before the hash chain was introduced.
+	assert.NoError(t, s.store.Audit().Create(&model.AuditEvent{Type: model.AuditLogin, Time: time.Now()}))
+	for i := 0; i < 5; i++ {
+		s.recordAudit(&model.AuditEvent{
+			Type:    model.AuditLogin,
+			Outcome: model.AuditSuccess,
+			Subject: "user",
+			Time:    time.Now(),
+		})
+	}
+
+	return s
+}
+
+func TestServer_AuditChain(t *testing.T) {
+	s := testAuditLog(t)
+
+	events, _ := s.store.Audit().Find(&model.AuditFilter{})
+	if assert.Len(t, events, 6) {
+		assert.Equal(t, int64(5), events[0].Seq)
+		assert.Equal(t, events[1].Hash, events[0].PrevHash)
+	}
+
+	report, err := verifyAuditLog(s.store.Audit(), s.signingKey)
+	assert.NoError(t, err)
+	assert.Equal(t, &auditReport{Events: 5, Unchained: 1, Checkpoints: 2}, report)
+
+	// A new instance picks the chain up where it ends.
+	other := newServer(s.store, &testMailer{}, s.config)
+	other.recordAudit(&model.AuditEvent{Type: model.AuditLogout, Time: time.Now()})
+	s.recordAudit(&model.AuditEvent{Type: model.AuditLogout, Time: time.Now()})
+	_, err = verifyAuditLog(s.store.Audit(), nil)
+	assert.NoError(t, err)
+}
+
+func TestServer_AuditChain_Tampered(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(events []*model.AuditEvent, checkpoints []*model.AuditCheckpoint)
+		seq    int64
+	}{
+		{
+			name: "edited event",
+			tamper: func(events []*model.AuditEvent, _ []*model.AuditCheckpoint) {
+				events[2].Outcome = model.AuditFailure
+			},
+			seq: 2,
+		},
+		{
+			name: "rehashed event",
+			tamper: func(events []*model.AuditEvent, _ []*model.AuditCheckpoint) {
+				events[2].Outcome = model.AuditFailure
+				events[2].Hash = events[2].ComputeHash()
+			},
+			seq: 3,
+		},
+		{
+			name: "rehashed tail",
+			tamper: func(events []*model.AuditEvent, _ []*model.AuditCheckpoint) {
+				for i := 4; i <= 5; i++ {
+					events[i].Outcome = model.AuditFailure
+					events[i].PrevHash = events[i-1].Hash
+					events[i].Hash = events[i].ComputeHash()
+				}
+			},
+			seq: 4,
+		},
+		{
+			name: "deleted event",
+			tamper: func(events []*model.AuditEvent, _ []*model.AuditCheckpoint) {
+				events[3].Seq = 0
+				events[3].Hash = ""
+				events[3].Time = events[0].Time
+			},
+			seq: 3,
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(_ []*model.AuditEvent, checkpoints []*model.AuditCheckpoint) {
+				checkpoints[0].Token = checkpoints[1].Token
+			},
+			seq: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := testAuditLog(t)
+			// events[0] is the unchained event, events[i] has seq i.
+			var events []*model.AuditEvent
+			_ = s.store.Audit().Walk(func(e *model.AuditEvent) error {
+				events = append(events, e)
+				return nil
+			})
+			checkpoints, _ := s.store.Audit().FindCheckpoints()
+			tc.tamper(events, checkpoints)
+
+			_, err := verifyAuditLog(s.store.Audit(), s.signingKey)
+			if assert.IsType(t, &auditBreak{}, err) {
+				assert.Equal(t, tc.seq, err.(*auditBreak).Seq, err.Error())
+			}
+		})
+	}
+}
+
+func TestServer_AuditChain_UnchainedAfterStart(t *testing.T) {
+	s := testAuditLog(t)
+	forged := &model.AuditEvent{Type: model.AuditLogin, Outcome: model.AuditSuccess, Subject: "user", Time: time.Now()}
+	assert.NoError(t, s.store.Audit().Create(forged))
+
+	_, err := verifyAuditLog(s.store.Audit(), s.signingKey)
+	if assert.IsType(t, &auditBreak{}, err) {
+		assert.Equal(t, forged.ID.Hex(), err.(*auditBreak).EventID, err.Error())
+	}
+}
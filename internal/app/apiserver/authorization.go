@@ -0,0 +1,123 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+const ctxKeyAccessDetails = "access_details"
+
+// authenticate verifies the access token and stores its AccessDetails in
+// the context for the handlers and the Require* middlewares.
+func (s *server) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := s.accessDetails(c); !ok {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScopes lets the request through only if the access token was
+// granted every one of scopes.
+func (s *server) RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		details, ok := s.accessDetails(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		if !details.HasScopes(scopes...) {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(scopes, " ")))
+			s.respond(c.Writer, c.Request, http.StatusForbidden, map[string]interface{}{
+				"error":           errInsufficientScope.Error(),
+				"code":            "insufficient_scope",
+				"required_scopes": scopes,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole lets the request through only if the access token carries
+// role.
+func (s *server) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		details, ok := s.accessDetails(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		if !details.HasRole(role) {
+			s.respond(c.Writer, c.Request, http.StatusForbidden, map[string]interface{}{
+				"error":         errInsufficientRole.Error(),
+				"code":          "insufficient_role",
+				"required_role": role,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// denyImpersonation keeps impersonation tokens away from the endpoints that
+// change how the user authenticates or hand out new credentials: an admin
+// acting as the user must not lock them out, nor get a token pair without
+// the act claim.
+func (s *server) denyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		details, ok := s.accessDetails(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		if details.Impersonated() {
+			s.errorCode(c.Writer, c.Request, http.StatusForbidden, "impersonation_not_allowed", errImpersonationToken)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// accessDetails returns the AccessDetails stored by a previous middleware,
+// or verifies the access token of the request. When the token is invalid
+// the error response is written and false is returned.
+func (s *server) accessDetails(c *gin.Context) (*model.AccessDetails, bool) {
+	if v, ok := c.Get(ctxKeyAccessDetails); ok {
+		return v.(*model.AccessDetails), true
+	}
+
+	claims, err := s.VerifyToken(c.Request)
+	if err != nil {
+		s.tokenError(c.Writer, c.Request, err)
+		return nil, false
+	}
+
+	details := &model.AccessDetails{
+		AccessUUID: claims.AccessUUID,
+		UserID:     claims.UserID,
+		ClientID:   claims.ClientID,
+		Roles:      claims.Roles,
+		Scopes:     model.SplitScopes(claims.Scope),
+		AuthTime:   claims.AuthTime,
+		ActorID:    claims.ActorID(),
+	}
+	c.Set(ctxKeyAccessDetails, details)
+
+	return details, true
+}
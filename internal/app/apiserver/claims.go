@@ -0,0 +1,87 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// Values of the typ header, so that an access token can never be accepted
+// as a refresh token and the other way round.
+const (
+	accessTokenType  = "at+jwt"
+	refreshTokenType = "refresh+jwt"
+)
+
+// verifiable is implemented by model.AccessClaims and model.RefreshClaims.
+type verifiable interface {
+	jwt.Claims
+	Verify(time.Time, time.Duration, string, []string) error
+}
+
+// parseToken checks the signature of tokenString with secret, decodes it
+// into claims and then checks the typ header and the claims against the
+// configuration. The errors returned are those of the model package.
+func (s *server) parseToken(tokenString string, typ string, secret []byte, claims verifiable) error {
+	if tokenString == "" {
+		return model.ErrTokenMissing
+	}
+
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil {
+		if vErr, ok := err.(*jwt.ValidationError); ok && vErr.Errors&jwt.ValidationErrorMalformed != 0 {
+			return model.ErrTokenMalformed
+		}
+		return model.ErrTokenSignatureInvalid
+	}
+
+	if t, _ := token.Header["typ"].(string); t != typ {
+		return model.ErrTokenWrongType
+	}
+	if err := claims.Valid(); err != nil {
+		return err
+	}
+
+	return claims.Verify(time.Now(), s.config.Tokens.Leeway.Duration, s.config.Tokens.Issuer, s.config.Tokens.Audiences)
+}
+
+// registeredClaims returns the registered claims shared by both token types.
+func (s *server) registeredClaims(subject, id string, issuedAt time.Time, expires int64) model.RegisteredClaims {
+	return model.RegisteredClaims{
+		Issuer:    s.config.Tokens.Issuer,
+		Audience:  s.config.Tokens.Audiences,
+		Subject:   subject,
+		ID:        id,
+		IssuedAt:  issuedAt.Unix(),
+		NotBefore: issuedAt.Unix(),
+		ExpiresAt: expires,
+	}
+}
+
+// tokenError responds to a failed token verification with a status and an
+// error code that tell the client what went wrong.
+func (s *server) tokenError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case model.ErrTokenMissing:
+		s.errorCode(w, r, http.StatusUnauthorized, "token_missing", err)
+	case model.ErrTokenMalformed:
+		s.errorCode(w, r, http.StatusBadRequest, "token_malformed", err)
+	case model.ErrTokenSignatureInvalid:
+		s.errorCode(w, r, http.StatusUnauthorized, "token_signature_invalid", err)
+	case model.ErrTokenWrongType:
+		s.errorCode(w, r, http.StatusUnauthorized, "token_wrong_type", err)
+	case model.ErrTokenExpired:
+		s.errorCode(w, r, http.StatusUnauthorized, "token_expired", err)
+	default:
+		s.errorCode(w, r, http.StatusUnauthorized, "token_invalid", err)
+	}
+}
@@ -1,16 +1,373 @@
 package apiserver
 
-// Config ...
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// Config ... DatabaseURL selects the store: a postgres:// URL for
+// PostgreSQL, a file:// URL for an embedded SQLite file and a Mongo URL
+// otherwise. Expired records of SQL stores are deleted every SweepInterval.
 type Config struct {
-	BindAddr    string `toml:"bind_addr"`
-	LogLevel    string `toml:"log_level"`
-	DatabaseURL string `toml:"database_url"`
+	BindAddr          string                  `toml:"bind_addr"`
+	LogLevel          string                  `toml:"log_level"`
+	DatabaseURL       string                  `toml:"database_url"`
+	SweepInterval     Duration                `toml:"sweep_interval"`
+	TokenStore        TokenStoreConfig        `toml:"token_store"`
+	Cookie            CookieConfig            `toml:"cookie"`
+	CSRF              CSRFConfig              `toml:"csrf"`
+	Tokens            TokensConfig            `toml:"tokens"`
+	OAuth             OAuthConfig             `toml:"oauth"`
+	OIDC              OIDCConfig              `toml:"oidc"`
+	MFA               MFAConfig               `toml:"mfa"`
+	Mailer            MailerConfig            `toml:"mailer"`
+	PasswordReset     PasswordResetConfig     `toml:"password_reset"`
+	EmailVerification EmailVerificationConfig `toml:"email_verification"`
+	Passwordless      PasswordlessConfig      `toml:"passwordless"`
+	Password          PasswordConfig          `toml:"password"`
+	Impersonation     ImpersonationConfig     `toml:"impersonation"`
+	Audit             AuditConfig             `toml:"audit"`
+	Webhooks          WebhooksConfig          `toml:"webhooks"`
+}
+
+// TokenStoreConfig selects where refresh sessions are kept. Driver is
+// "database", the database at DatabaseURL, or "redis", the server at URL,
+// such as "redis://localhost:6379/0". "mongodb", its name from when Mongo
+// was the only database, is still accepted for "database".
+type TokenStoreConfig struct {
+	Driver string `toml:"driver"`
+	URL    string `toml:"url"`
+}
+
+// CookieConfig holds the attributes of the refresh token cookie.
+type CookieConfig struct {
+	Name       string   `toml:"name"`
+	Domain     string   `toml:"domain"`
+	Paths      []string `toml:"paths"`
+	Secure     bool     `toml:"secure"`
+	SameSite   string   `toml:"same_site"`
+	HostPrefix bool     `toml:"host_prefix"`
+}
+
+// CSRFConfig holds the settings of the CSRF protection applied to the
+// cookie-authenticated endpoints.
+type CSRFConfig struct {
+	CookieName     string   `toml:"cookie_name"`
+	HeaderName     string   `toml:"header_name"`
+	TrustedOrigins []string `toml:"trusted_origins"`
+}
+
+// TokensConfig holds the registered claims expected in tokens and the token
+// lifetimes. Issuer is published in the OpenID Connect discovery document
+// and must be the https URL the server is reached at. A client override
+// replaces the default lifetimes of tokens issued to the authenticated
+// client, and a role override can only shorten them.
+type TokensConfig struct {
+	Issuer             string              `toml:"issuer"`
+	Audiences          []string            `toml:"audiences"`
+	Leeway             Duration            `toml:"leeway"`
+	AccessTTL          Duration            `toml:"access_ttl"`
+	RefreshTTL         Duration            `toml:"refresh_ttl"`
+	MaxSessionLifetime Duration            `toml:"max_session_lifetime"`
+	Clients            map[string]TokenTTL `toml:"clients"`
+	Roles              map[string]TokenTTL `toml:"roles"`
+}
+
+// validate checks that Issuer is a URL OpenID Connect clients accept: https,
+// or http on a loopback host for development, without a query or fragment.
+func (c *TokensConfig) validate() error {
+	u, err := url.Parse(c.Issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" ||
+		(u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname()))) {
+		return fmt.Errorf("tokens.issuer must be an https URL, got %q", c.Issuer)
+	}
+
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// TokenTTL overrides the token lifetimes. Zero values keep the default.
+type TokenTTL struct {
+	AccessTTL  Duration `toml:"access_ttl"`
+	RefreshTTL Duration `toml:"refresh_ttl"`
+}
+
+// OAuthConfig holds the settings of the OAuth 2.0 endpoints.
+type OAuthConfig struct {
+	CodeTTL Duration `toml:"code_ttl"`
+}
+
+// OIDCConfig holds the settings of the OpenID Connect provider. ID tokens,
+// MFA challenges, magic links and audit checkpoints are signed with the RSA
+// key in SigningKeyFile. It is required unless GenerateSigningKey is set
+// for development: a key generated at startup stops verifying after a
+// restart and is not shared between instances.
+type OIDCConfig struct {
+	SigningKeyFile     string `toml:"signing_key_file"`
+	GenerateSigningKey bool   `toml:"generate_signing_key"`
+}
+
+func (c *OIDCConfig) validate() error {
+	if c.SigningKeyFile == "" && !c.GenerateSigningKey {
+		return errors.New("oidc.signing_key_file must be set; set oidc.generate_signing_key for development only")
+	}
+
+	return nil
+}
+
+// MFAConfig holds the settings of two-factor authentication. A user who
+// fails MaxAttempts codes in a row, over any number of challenges, may try
+// again once per LockoutDuration until a code succeeds. The failures are
+// counted in the store, so that a restart or another instance does not
+// reset them.
+type MFAConfig struct {
+	Issuer          string   `toml:"issuer"`
+	ChallengeTTL    Duration `toml:"challenge_ttl"`
+	MaxAttempts     int      `toml:"max_attempts"`
+	LockoutDuration Duration `toml:"lockout_duration"`
+}
+
+// MailerConfig selects how emails are sent. Driver is "smtp" or "log"; the
+// log driver appends messages to File, or writes them to the standard error
+// when File is empty.
+type MailerConfig struct {
+	Driver   string `toml:"driver"`
+	Addr     string `toml:"addr"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	From     string `toml:"from"`
+	File     string `toml:"file"`
+}
+
+// PasswordResetConfig holds the settings of the password reset flow. The
+// reset token is appended to URL as the token query parameter.
+type PasswordResetConfig struct {
+	URL      string   `toml:"url"`
+	TokenTTL Duration `toml:"token_ttl"`
+}
+
+// EmailVerificationConfig holds the settings of email verification. When
+// Required is set, users cannot log in before verifying their email and
+// /Login answers email_not_verified. The verification token is appended to
+// URL as the token query parameter. At most ResendLimit emails are resent
+// per address within ResendWindow, and no sooner than ResendInterval after
+// the previous one.
+type EmailVerificationConfig struct {
+	Required       bool     `toml:"required"`
+	URL            string   `toml:"url"`
+	TokenTTL       Duration `toml:"token_ttl"`
+	ResendInterval Duration `toml:"resend_interval"`
+	ResendLimit    int      `toml:"resend_limit"`
+	ResendWindow   Duration `toml:"resend_window"`
+}
+
+// PasswordlessConfig holds the settings of passwordless login. The magic
+// link token is appended to LinkURL as the token query parameter; the page
+// there must post it to /passwordless/verify. A code may be tried
+// MaxAttempts times per address within CodeTTL. At most SendLimit links or
+// codes are sent per address within SendWindow, no sooner than SendInterval
+// after the previous one, and at most SendLimitPerIP per client IP.
+type PasswordlessConfig struct {
+	LinkURL        string   `toml:"link_url"`
+	LinkTTL        Duration `toml:"link_ttl"`
+	CodeTTL        Duration `toml:"code_ttl"`
+	MaxAttempts    int      `toml:"max_attempts"`
+	SendInterval   Duration `toml:"send_interval"`
+	SendLimit      int      `toml:"send_limit"`
+	SendLimitPerIP int      `toml:"send_limit_per_ip"`
+	SendWindow     Duration `toml:"send_window"`
+}
+
+// PasswordConfig holds the settings of user passwords: the policy new
+// passwords must follow and how they are hashed. BreachedDir is a local
+// copy of a k-anonymity breached password dataset, see
+// model.BreachedPasswordDir; the check is skipped when it is empty.
+type PasswordConfig struct {
+	MinLength     int          `toml:"min_length"`
+	MaxLength     int          `toml:"max_length"`
+	RequireUpper  bool         `toml:"require_upper"`
+	RequireLower  bool         `toml:"require_lower"`
+	RequireDigit  bool         `toml:"require_digit"`
+	RequireSymbol bool         `toml:"require_symbol"`
+	BannedWords   []string     `toml:"banned_words"`
+	BreachedDir   string       `toml:"breached_dir"`
+	Argon2        Argon2Config `toml:"argon2"`
+}
+
+func (c *PasswordConfig) policy() *model.PasswordPolicy {
+	p := &model.PasswordPolicy{
+		MinLength:     c.MinLength,
+		MaxLength:     c.MaxLength,
+		RequireUpper:  c.RequireUpper,
+		RequireLower:  c.RequireLower,
+		RequireDigit:  c.RequireDigit,
+		RequireSymbol: c.RequireSymbol,
+		BannedWords:   c.BannedWords,
+	}
+	if c.BreachedDir != "" {
+		p.Breached = model.NewBreachedPasswordDir(c.BreachedDir)
+	}
+
+	return p
+}
+
+// Argon2Config holds the argon2id parameters new password hashes are made
+// with. Memory is in KiB. Hashes made with other parameters are replaced on
+// the next login.
+type Argon2Config struct {
+	Time       uint32 `toml:"time"`
+	Memory     uint32 `toml:"memory"`
+	Threads    uint8  `toml:"threads"`
+	KeyLength  uint32 `toml:"key_length"`
+	SaltLength uint32 `toml:"salt_length"`
+}
+
+func (c *Argon2Config) params() model.Argon2Params {
+	return model.Argon2Params{
+		Time:       c.Time,
+		Memory:     c.Memory,
+		Threads:    c.Threads,
+		KeyLength:  c.KeyLength,
+		SaltLength: c.SaltLength,
+	}
+}
+
+// ImpersonationConfig holds the settings of impersonation tokens.
+type ImpersonationConfig struct {
+	TokenTTL Duration `toml:"token_ttl"`
+}
+
+// AuditConfig holds the settings of the audit log. Every CheckpointEvery
+// events a checkpoint signed with the OIDC signing key is written; it can
+// only be verified later if that key is kept in a file.
+type AuditConfig struct {
+	CheckpointEvery int64 `toml:"checkpoint_every"`
+}
+
+// WebhooksConfig holds the settings of webhook deliveries. Pending
+// deliveries are polled every PollInterval and claimed for Lease, which
+// should exceed Timeout. A failed delivery is retried after BaseDelay,
+// doubled on each attempt up to MaxDelay, and is dead after MaxAttempts.
+type WebhooksConfig struct {
+	Timeout      Duration `toml:"timeout"`
+	PollInterval Duration `toml:"poll_interval"`
+	Lease        Duration `toml:"lease"`
+	MaxAttempts  int      `toml:"max_attempts"`
+	BaseDelay    Duration `toml:"base_delay"`
+	MaxDelay     Duration `toml:"max_delay"`
+}
+
+// Duration is a time.Duration read from a string such as "15m".
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText ...
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
 }
 
 // NewConfig ...
 func NewConfig() *Config {
 	return &Config{
-		BindAddr: ":8080",
-		LogLevel: "debug",
+		BindAddr:      ":8080",
+		LogLevel:      "debug",
+		SweepInterval: Duration{time.Minute},
+		TokenStore: TokenStoreConfig{
+			Driver: "database",
+		},
+		Cookie: CookieConfig{
+			Name:     "refresh_token",
+			Paths:    []string{"/Refresh", "/Logout", "/LogoutAll"},
+			Secure:   true,
+			SameSite: "strict",
+		},
+		CSRF: CSRFConfig{
+			CookieName: "csrf_token",
+			HeaderName: "X-CSRF-Token",
+		},
+		Tokens: TokensConfig{
+			Issuer:             "http://localhost:8080",
+			Audiences:          []string{"go-test-work"},
+			Leeway:             Duration{30 * time.Second},
+			AccessTTL:          Duration{15 * time.Minute},
+			RefreshTTL:         Duration{7 * 24 * time.Hour},
+			MaxSessionLifetime: Duration{30 * 24 * time.Hour},
+		},
+		OAuth: OAuthConfig{
+			CodeTTL: Duration{time.Minute},
+		},
+		MFA: MFAConfig{
+			Issuer:          "go-test-work",
+			ChallengeTTL:    Duration{5 * time.Minute},
+			MaxAttempts:     5,
+			LockoutDuration: Duration{15 * time.Minute},
+		},
+		Mailer: MailerConfig{
+			Driver: "log",
+			From:   "no-reply@localhost",
+		},
+		PasswordReset: PasswordResetConfig{
+			URL:      "http://localhost:8080/reset-password",
+			TokenTTL: Duration{time.Hour},
+		},
+		EmailVerification: EmailVerificationConfig{
+			Required:       true,
+			URL:            "http://localhost:8080/verify-email",
+			TokenTTL:       Duration{24 * time.Hour},
+			ResendInterval: Duration{time.Minute},
+			ResendLimit:    5,
+			ResendWindow:   Duration{time.Hour},
+		},
+		Passwordless: PasswordlessConfig{
+			LinkURL:        "http://localhost:8080/passwordless/verify",
+			LinkTTL:        Duration{10 * time.Minute},
+			CodeTTL:        Duration{10 * time.Minute},
+			MaxAttempts:    5,
+			SendInterval:   Duration{time.Minute},
+			SendLimit:      5,
+			SendLimitPerIP: 20,
+			SendWindow:     Duration{time.Hour},
+		},
+		Password: PasswordConfig{
+			MinLength: 8,
+			MaxLength: 100,
+			Argon2: Argon2Config{
+				Time:       model.DefaultArgon2Params.Time,
+				Memory:     model.DefaultArgon2Params.Memory,
+				Threads:    model.DefaultArgon2Params.Threads,
+				KeyLength:  model.DefaultArgon2Params.KeyLength,
+				SaltLength: model.DefaultArgon2Params.SaltLength,
+			},
+		},
+		Impersonation: ImpersonationConfig{
+			TokenTTL: Duration{5 * time.Minute},
+		},
+		Audit: AuditConfig{
+			CheckpointEvery: 100,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:      Duration{10 * time.Second},
+			PollInterval: Duration{5 * time.Second},
+			Lease:        Duration{time.Minute},
+			MaxAttempts:  8,
+			BaseDelay:    Duration{30 * time.Second},
+			MaxDelay:     Duration{time.Hour},
+		},
 	}
 }
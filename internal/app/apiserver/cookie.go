@@ -0,0 +1,84 @@
+package apiserver
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+const hostCookiePrefix = "__Host-"
+
+// cookieName returns the refresh cookie name, prefixed with __Host- when
+// the prefix is enabled.
+func (c *CookieConfig) cookieName() string {
+	if c.HostPrefix {
+		return hostCookiePrefix + c.Name
+	}
+	return c.Name
+}
+
+// cookiePaths returns the paths the refresh cookie is scoped to. A __Host-
+// cookie must have Path=/, so the configured paths are ignored then.
+func (c *CookieConfig) cookiePaths() []string {
+	if c.HostPrefix || len(c.Paths) == 0 {
+		return []string{"/"}
+	}
+	return c.Paths
+}
+
+func (c *CookieConfig) sameSite() http.SameSite {
+	switch strings.ToLower(c.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
+
+func (c *CookieConfig) cookies(value string, expires time.Time, maxAge int) []*http.Cookie {
+	// __Host- cookies are rejected by browsers unless they are Secure and
+	// carry no Domain attribute.
+	secure, domain := c.Secure, c.Domain
+	if c.HostPrefix {
+		secure, domain = true, ""
+	}
+
+	paths := c.cookiePaths()
+	cookies := make([]*http.Cookie, 0, len(paths))
+	for _, path := range paths {
+		cookies = append(cookies, &http.Cookie{
+			Name:     c.cookieName(),
+			Value:    value,
+			Path:     path,
+			Domain:   domain,
+			Expires:  expires,
+			MaxAge:   maxAge,
+			Secure:   secure,
+			HttpOnly: true,
+			SameSite: c.sameSite(),
+		})
+	}
+	return cookies
+}
+
+func (s *server) setRefreshCookie(w http.ResponseWriter, td *model.TokenDetails) error {
+	expires := time.Unix(td.RtExpires, 0)
+	maxAge := int(time.Until(expires).Seconds())
+	for _, cookie := range s.config.Cookie.cookies(td.RefreshToken, expires, maxAge) {
+		http.SetCookie(w, cookie)
+	}
+	return s.setCSRFCookie(w, td)
+}
+
+func (s *server) clearRefreshCookie(w http.ResponseWriter) {
+	for _, cookie := range s.config.Cookie.cookies("", time.Unix(0, 0), -1) {
+		http.SetCookie(w, cookie)
+	}
+	s.clearCSRFCookie(w)
+}
@@ -0,0 +1,150 @@
+package apiserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+const refreshTokenHeader = "X-Refresh-Token"
+
+// csrfProtect guards the endpoints that authenticate with the refresh
+// cookie. Requests must come from a trusted origin and echo the value of
+// the CSRF cookie in the CSRF header. Clients that send the refresh token
+// explicitly in the X-Refresh-Token header are not exposed to CSRF and skip
+// the check.
+func (s *server) csrfProtect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(refreshTokenHeader) != "" {
+			c.Next()
+			return
+		}
+
+		if _, err := c.Request.Cookie(s.config.Cookie.cookieName()); err != nil {
+			c.Next()
+			return
+		}
+
+		if !s.trustedOrigin(c.Request) {
+			s.error(c.Writer, c.Request, http.StatusForbidden, errUntrustedOrigin)
+			c.Abort()
+			return
+		}
+
+		cookie, err := c.Request.Cookie(s.csrfCookieName())
+		if err != nil || cookie.Value == "" {
+			s.error(c.Writer, c.Request, http.StatusForbidden, errCSRFTokenMismatch)
+			c.Abort()
+			return
+		}
+
+		header := c.GetHeader(s.config.CSRF.HeaderName)
+		if subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+			s.error(c.Writer, c.Request, http.StatusForbidden, errCSRFTokenMismatch)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// trustedOrigin checks the Origin header, falling back to the Referer,
+// against the origin of the request and the configured trusted origins. Requests
+// carrying neither header are let through, as they do not come from a
+// browser that would attach the cookie on its own.
+func (s *server) trustedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return true
+		}
+
+		u, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Scheme+"://"+u.Host, requestOrigin(r)) {
+		return true
+	}
+
+	for _, trusted := range s.config.CSRF.TrustedOrigins {
+		if origin == trusted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestOrigin returns the scheme and host the request was made to, as
+// seen by the client when a proxy terminates TLS.
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host
+}
+
+func (s *server) csrfCookieName() string {
+	if s.config.Cookie.HostPrefix {
+		return hostCookiePrefix + s.config.CSRF.CookieName
+	}
+	return s.config.CSRF.CookieName
+}
+
+// csrfCookie builds the double-submit cookie. It is readable by scripts so
+// that the client can copy it into the CSRF header.
+func (s *server) csrfCookie(value string, expires time.Time, maxAge int) *http.Cookie {
+	secure, domain := s.config.Cookie.Secure, s.config.Cookie.Domain
+	if s.config.Cookie.HostPrefix {
+		secure, domain = true, ""
+	}
+
+	return &http.Cookie{
+		Name:     s.csrfCookieName(),
+		Value:    value,
+		Path:     "/",
+		Domain:   domain,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Secure:   secure,
+		SameSite: s.config.Cookie.sameSite(),
+	}
+}
+
+func (s *server) setCSRFCookie(w http.ResponseWriter, td *model.TokenDetails) error {
+	value, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	expires := time.Unix(td.RtExpires, 0)
+	maxAge := int(time.Until(expires).Seconds())
+	http.SetCookie(w, s.csrfCookie(value, expires, maxAge))
+
+	return nil
+}
+
+func (s *server) clearCSRFCookie(w http.ResponseWriter) {
+	http.SetCookie(w, s.csrfCookie("", time.Unix(0, 0), -1))
+}
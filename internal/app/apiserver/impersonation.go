@@ -0,0 +1,74 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// HandleAdminImpersonate issues a short-lived access token that lets an
+// admin act as a user. The token carries an act claim naming the admin,
+// has no refresh token and never grants the admin scope.
+func (s *server) HandleAdminImpersonate(c *gin.Context) {
+	type request struct {
+		Reason string `json:"reason"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil && err != io.EOF {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	details, _ := s.accessDetails(c)
+	if details.Impersonated() {
+		s.errorCode(c.Writer, c.Request, http.StatusForbidden, "impersonation_nested", errImpersonating)
+		return
+	}
+	// A client token has no user to name in the act claim, and the token
+	// would pass for the user's own.
+	if details.UserID == "" {
+		s.errorCode(c.Writer, c.Request, http.StatusForbidden, "impersonation_requires_user", errImpersonationActor)
+		return
+	}
+
+	u, ok := s.adminFindUser(c)
+	if !ok {
+		return
+	}
+	if !s.checkUserStatus(c, u) {
+		return
+	}
+
+	var scopes []string
+	for _, scope := range u.Scopes {
+		if scope != model.ScopeAdmin {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	ts, err := s.Create(&model.TokenRequest{
+		UserID:     u.ID.Hex(),
+		Roles:      u.Roles,
+		Scopes:     scopes,
+		AccessTTL:  s.config.Impersonation.TokenTTL.Duration,
+		AccessOnly: true,
+		ActorID:    details.UserID,
+	})
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	auditSession(c, ts.AccessUuid)
+	c.Set(ctxKeyAuditReason, req.Reason)
+
+	c.Header("Cache-Control", "no-store")
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]interface{}{
+		"access_token": ts.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int64(s.config.Impersonation.TokenTTL.Seconds()),
+	})
+}
@@ -0,0 +1,85 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleAdminImpersonate(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+	admin := model.TestUser(t)
+	admin.Email = "admin@example.org"
+	admin.Scopes = []string{model.ScopeAdmin}
+	adminTokens, _ := testLoginUser(t, s, admin)
+	u := &model.User{Email: "user@example.com", EmailVerified: true, Password: "password", Scopes: []string{"orders", model.ScopeAdmin}}
+	testLoginUser(t, s, u)
+	plain := &model.User{Email: "plain@example.com", EmailVerified: true, Password: "password"}
+	plainTokens, _ := testLoginUser(t, s, plain)
+
+	path := "/admin/users/" + u.ID.Hex() + "/impersonate"
+	rec := testJSONRequest(s, path, plainTokens["access_token"], nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = testJSONRequest(s, path, adminTokens["access_token"], map[string]string{"reason": "ticket 42"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	res := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	assert.Nil(t, res["refresh_token"])
+	assert.Equal(t, float64(s.config.Impersonation.TokenTTL.Seconds()), res["expires_in"])
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+res["access_token"].(string))
+	claims, err := s.VerifyToken(req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, u.ID.Hex(), claims.UserID)
+		assert.Equal(t, admin.ID.Hex(), claims.ActorID())
+		assert.Equal(t, "orders", claims.Scope)
+		assert.Equal(t, s.config.Impersonation.TokenTTL.Duration.Nanoseconds()/1e9, claims.ExpiresAt-claims.IssuedAt)
+	}
+
+	if err := s.store.Client().Create(&model.Client{
+		ID:            "spa",
+		RedirectURIs:  []string{"https://app.example.org/callback"},
+		AllowedScopes: []string{"orders"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/authorize?"+testAuthorizeQuery(url.Values{"scope": {"orders"}}).Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+res["access_token"].(string))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+
+	for _, path := range []string{"/mfa/totp/enroll", "/mfa/totp/confirm"} {
+		rec = testJSONRequest(s, path, res["access_token"].(string), map[string]string{"code": "000000"})
+		assert.Equal(t, http.StatusForbidden, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), "impersonation_not_allowed", path)
+	}
+	assert.Empty(t, u.TOTPSecret)
+
+	clientToken, err := s.Create(&model.TokenRequest{
+		ClientID:   "backoffice",
+		Scopes:     []string{model.ScopeAdmin},
+		AccessOnly: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = testJSONRequest(s, path, clientToken.AccessToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "impersonation_requires_user")
+
+	u.Status = model.UserStatusDisabled
+	rec = testJSONRequest(s, path, adminTokens["access_token"], nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "account_disabled")
+}
@@ -0,0 +1,50 @@
+package apiserver
+
+import (
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// lifetimes returns the access and refresh token lifetimes of a token
+// request. The override of the client replaces the defaults, but only when
+// the client was authenticated, since anyone can name a client in a login.
+// A role override can only shorten the result, so that no client lifts
+// the limits of a role. When several roles have an override, the shortest
+// lifetime wins.
+func (c *TokensConfig) lifetimes(tr *model.TokenRequest) (time.Duration, time.Duration) {
+	accessTTL, refreshTTL := c.AccessTTL.Duration, c.RefreshTTL.Duration
+
+	if ttl, ok := c.Clients[tr.ClientID]; ok && tr.ClientAuthenticated {
+		if ttl.AccessTTL.Duration > 0 {
+			accessTTL = ttl.AccessTTL.Duration
+		}
+		if ttl.RefreshTTL.Duration > 0 {
+			refreshTTL = ttl.RefreshTTL.Duration
+		}
+	}
+
+	for _, role := range tr.Roles {
+		ttl, ok := c.Roles[role]
+		if !ok {
+			continue
+		}
+		if ttl.AccessTTL.Duration > 0 && ttl.AccessTTL.Duration < accessTTL {
+			accessTTL = ttl.AccessTTL.Duration
+		}
+		if ttl.RefreshTTL.Duration > 0 && ttl.RefreshTTL.Duration < refreshTTL {
+			refreshTTL = ttl.RefreshTTL.Duration
+		}
+	}
+
+	return accessTTL, refreshTTL
+}
+
+// sessionEnd returns the moment a session started at authTime must end, or
+// the zero time when the session lifetime is not limited.
+func (c *TokensConfig) sessionEnd(authTime int64) time.Time {
+	if c.MaxSessionLifetime.Duration <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(authTime, 0).Add(c.MaxSessionLifetime.Duration)
+}
@@ -0,0 +1,265 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/twinj/uuid"
+)
+
+const (
+	mfaChallengeType  = "mfa+jwt"
+	recoveryCodeCount = 10
+)
+
+// HandleTOTPEnroll generates a TOTP secret for the user. It takes effect
+// once confirmed with a first code.
+func (s *server) HandleTOTPEnroll(c *gin.Context) {
+	details, _ := s.accessDetails(c)
+
+	u, err := s.store.User().Find(details.UserID)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnauthorized, errUnknownUser)
+		return
+	}
+	if u.TOTPEnabled {
+		s.error(c.Writer, c.Request, http.StatusConflict, errMFAEnabled)
+		return
+	}
+
+	secret, err := model.NewTOTPSecret()
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	u.TOTPSecret = secret
+	if err := s.store.User().UpdateTOTP(u); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": model.TOTPURI(secret, s.config.MFA.Issuer, u.Email),
+	})
+}
+
+// HandleTOTPConfirm enables TOTP once the user proves the authenticator app
+// works, and returns the recovery codes. They are shown only this once.
+func (s *server) HandleTOTPConfirm(c *gin.Context) {
+	type request struct {
+		Code string `json:"code"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	details, _ := s.accessDetails(c)
+
+	u, err := s.store.User().Find(details.UserID)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnauthorized, errUnknownUser)
+		return
+	}
+	if u.TOTPEnabled {
+		s.error(c.Writer, c.Request, http.StatusConflict, errMFAEnabled)
+		return
+	}
+	if u.TOTPSecret == "" {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, errMFANotEnrolled)
+		return
+	}
+
+	step, ok := model.VerifyTOTP(u.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		s.error(c.Writer, c.Request, http.StatusUnprocessableEntity, errInvalidMFACode)
+		return
+	}
+
+	codes, err := model.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	u.TOTPEnabled = true
+	u.TOTPLastStep = step
+	u.RecoveryCodes = make([]string, len(codes))
+	for i, code := range codes {
+		u.RecoveryCodes[i] = model.HashToken(code)
+	}
+	if err := s.store.User().UpdateTOTP(u); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string][]string{
+		"recovery_codes": codes,
+	})
+}
+
+// HandleMFASessionsCreate is the second step of a login: it exchanges the
+// challenge token returned by /Login and a TOTP or recovery code for the
+// token pair. A challenge is used up by a successful exchange, or when the
+// user is locked out after MFA.MaxAttempts failed codes in a row.
+func (s *server) HandleMFASessionsCreate(c *gin.Context) {
+	type request struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	claims := &model.ChallengeClaims{}
+	if err := s.verifyChallenge(req.ChallengeToken, claims); err != nil {
+		s.tokenError(c.Writer, c.Request, err)
+		return
+	}
+	auditSubject(c, claims.UserID)
+
+	u, err := s.store.User().Find(claims.UserID)
+	if err != nil || !u.TOTPEnabled {
+		s.error(c.Writer, c.Request, http.StatusUnauthorized, errUnknownUser)
+		return
+	}
+	if !s.checkUserStatus(c, u) {
+		return
+	}
+
+	now := time.Now()
+	if u.MFALocked(now, s.config.MFA.MaxAttempts, s.config.MFA.LockoutDuration.Duration) {
+		if _, err := s.store.OneTimeToken().Consume(model.HashToken(claims.ID), model.PurposeMFAChallenge); err != nil && err != store.ErrRecordNotFound {
+			s.logger.Errorf("invalidate mfa challenge: %v", err)
+		}
+		wait := u.MFALastFailure.Add(s.config.MFA.LockoutDuration.Duration).Sub(now)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		s.errorCode(c.Writer, c.Request, http.StatusTooManyRequests, "too_many_attempts", errTooManyAttempts)
+		return
+	}
+
+	// The code is checked before the challenge is used up, so that a typo
+	// does not cost the user the challenge.
+	var step int64
+	recoveryHash := ""
+	switch {
+	case req.Code != "":
+		var ok bool
+		step, ok = model.VerifyTOTP(u.TOTPSecret, req.Code, now)
+		if !ok {
+			s.mfaFailed(c, claims.UserID, now)
+			return
+		}
+	case req.RecoveryCode != "":
+		recoveryHash = model.HashToken(model.NormalizeRecoveryCode(req.RecoveryCode))
+		if !u.HasRecoveryCode(recoveryHash) {
+			s.mfaFailed(c, claims.UserID, now)
+			return
+		}
+	default:
+		s.errorCode(c.Writer, c.Request, http.StatusBadRequest, "mfa_code_missing", errInvalidMFACode)
+		return
+	}
+
+	_, err = s.store.OneTimeToken().Consume(model.HashToken(claims.ID), model.PurposeMFAChallenge)
+	if err == store.ErrRecordNotFound {
+		s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "mfa_challenge_invalid", errInvalidChallenge)
+		return
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	var used bool
+	if recoveryHash != "" {
+		used, err = s.store.User().UseRecoveryCode(claims.UserID, recoveryHash)
+	} else {
+		used, err = s.store.User().UseTOTPStep(claims.UserID, step)
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+	if !used {
+		s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "mfa_code_reused", errMFACodeReused)
+		return
+	}
+
+	if u.MFAFailures > 0 {
+		if err := s.store.User().ResetMFAFailures(claims.UserID); err != nil {
+			s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	s.startSession(c, u, claims.ClientID)
+}
+
+// mfaFailed counts a wrong code against the user and responds.
+func (s *server) mfaFailed(c *gin.Context, userID string, now time.Time) {
+	if _, err := s.store.User().AddMFAFailure(userID, now); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "mfa_code_invalid", errInvalidMFACode)
+}
+
+// respondMFAChallenge answers a login of a user with two-factor
+// authentication with the challenge token for the second step.
+func (s *server) respondMFAChallenge(c *gin.Context, u *model.User, clientID string) {
+	now := time.Now()
+	expires := now.Add(s.config.MFA.ChallengeTTL.Duration)
+	jti := uuid.NewV4().String()
+
+	token, err := s.signingKey.sign(&model.ChallengeClaims{
+		RegisteredClaims: s.registeredClaims(u.ID.Hex(), jti, now, expires.Unix()),
+		UserID:           u.ID.Hex(),
+		ClientID:         clientID,
+	}, mfaChallengeType)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := s.store.OneTimeToken().Create(&model.OneTimeToken{
+		Hash:      model.HashToken(jti),
+		Purpose:   model.PurposeMFAChallenge,
+		UserID:    u.ID.Hex(),
+		ClientID:  clientID,
+		ExpiresAt: expires,
+	}); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]interface{}{
+		"mfa_required":    true,
+		"challenge_token": token,
+		"expires_in":      int64(s.config.MFA.ChallengeTTL.Seconds()),
+	})
+}
+
+func (s *server) verifyChallenge(tokenString string, claims *model.ChallengeClaims) error {
+	if tokenString == "" {
+		return model.ErrTokenMissing
+	}
+	if err := s.signingKey.verify(tokenString, mfaChallengeType, claims); err != nil {
+		return err
+	}
+
+	return claims.Verify(time.Now(), s.config.Tokens.Leeway.Duration, s.config.Tokens.Issuer, s.config.Tokens.Audiences)
+}
@@ -0,0 +1,180 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_TOTP(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	u := model.TestUser(t)
+	tokens, _ := testLoginUser(t, s, u)
+
+	rec := testJSONRequest(s, "/mfa/totp/enroll", tokens["access_token"], nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	enrollment := map[string]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&enrollment)
+	assert.Contains(t, enrollment["otpauth_uri"], "otpauth://totp/")
+
+	step := model.TOTPStep(time.Now())
+	code, _ := model.TOTPCode(enrollment["secret"], step)
+	rec = testJSONRequest(s, "/mfa/totp/confirm", tokens["access_token"], map[string]string{"code": "000000"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = testJSONRequest(s, "/mfa/totp/confirm", tokens["access_token"], map[string]string{"code": code})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	recovery := map[string][]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&recovery)
+	assert.Len(t, recovery["recovery_codes"], recoveryCodeCount)
+
+	challenge := func() string {
+		rec := testJSONRequest(s, "/Login", "", map[string]string{"email": u.Email, "password": "password"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		res := map[string]interface{}{}
+		_ = json.NewDecoder(rec.Body).Decode(&res)
+		assert.Equal(t, true, res["mfa_required"])
+		assert.Nil(t, res["access_token"])
+		return res["challenge_token"].(string)
+	}
+
+	nextCode, _ := model.TOTPCode(enrollment["secret"], step+1)
+	testCases := []struct {
+		name         string
+		payload      map[string]string
+		expectedCode int
+	}{
+		{
+			name:         "code of the confirmation step",
+			payload:      map[string]string{"code": code},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "valid code",
+			payload:      map[string]string{"code": nextCode},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "replayed code",
+			payload:      map[string]string{"code": nextCode},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "recovery code",
+			payload:      map[string]string{"recovery_code": recovery["recovery_codes"][0]},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "used recovery code",
+			payload:      map[string]string{"recovery_code": recovery["recovery_codes"][0]},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "invalid challenge",
+			payload:      map[string]string{"challenge_token": "invalid", "code": nextCode},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := map[string]string{"challenge_token": challenge()}
+			for k, v := range tc.payload {
+				payload[k] = v
+			}
+			rec := testJSONRequest(s, "/LoginMFA", "", payload)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestServer_MFALockout(t *testing.T) {
+	config := NewConfig()
+	config.MFA.MaxAttempts = 2
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), config)
+	u := model.TestUser(t)
+	assert.NoError(t, s.store.User().Create(u))
+	u.TOTPSecret, _ = model.NewTOTPSecret()
+	u.TOTPEnabled = true
+
+	challenge := func() string {
+		rec := testJSONRequest(s, "/Login", "", map[string]string{"email": u.Email, "password": "password"})
+		res := map[string]interface{}{}
+		_ = json.NewDecoder(rec.Body).Decode(&res)
+		return res["challenge_token"].(string)
+	}
+	code, _ := model.TOTPCode(u.TOTPSecret, model.TOTPStep(time.Now()))
+	exchange := func(token string, code string) *httptest.ResponseRecorder {
+		u.TOTPLastStep = 0
+		return testJSONRequest(s, "/LoginMFA", "", map[string]string{"challenge_token": token, "code": code})
+	}
+
+	// A challenge is used up by a successful exchange.
+	token := challenge()
+	rec := exchange(token, code)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = exchange(token, code)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "mfa_challenge_invalid")
+
+	// Failures add up over challenges, and then even the right code is
+	// refused and uses up the challenge.
+	for i := 0; i < config.MFA.MaxAttempts; i++ {
+		rec = exchange(challenge(), "000000")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	assert.Equal(t, config.MFA.MaxAttempts, u.MFAFailures)
+	token = challenge()
+	rec = exchange(token, code)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	_, err := s.store.OneTimeToken().Consume(model.HashToken(testChallengeID(t, s, token)), model.PurposeMFAChallenge)
+	assert.Error(t, err)
+
+	// After the lockout a single try is allowed per lockout period.
+	u.MFALastFailure = time.Now().Add(-config.MFA.LockoutDuration.Duration)
+	rec = exchange(challenge(), "000000")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = exchange(challenge(), code)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// A successful code clears the failures.
+	u.MFALastFailure = time.Now().Add(-config.MFA.LockoutDuration.Duration)
+	rec = exchange(challenge(), code)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Zero(t, u.MFAFailures)
+}
+
+func testChallengeID(t *testing.T, s *server, token string) string {
+	t.Helper()
+
+	claims := &model.ChallengeClaims{}
+	if err := s.verifyChallenge(token, claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims.ID
+}
+
+func testJSONRequest(s *server, path string, accessToken string, payload interface{}) *httptest.ResponseRecorder {
+	b := &bytes.Buffer{}
+	if payload != nil {
+		_ = json.NewEncoder(b).Encode(payload)
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, path, b)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	s.ServeHTTP(rec, req)
+
+	return rec
+}
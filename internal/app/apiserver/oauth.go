@@ -0,0 +1,321 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// HandleAuthorize implements the authorization endpoint of the
+// authorization code grant. The user is identified by the access token, and
+// PKCE with the S256 method is mandatory.
+func (s *server) HandleAuthorize(c *gin.Context) {
+	details, _ := s.accessDetails(c)
+
+	if err := c.Request.ParseForm(); err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	form := c.Request.Form
+
+	// Until the client and its redirect URI are known to be valid, errors
+	// are reported to the user agent instead of being redirected.
+	client, err := s.store.Client().Find(form.Get("client_id"))
+	if err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_client", "unknown client")
+		return
+	}
+
+	redirectURI := form.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.HasRedirectURI(redirectURI) {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_request", "redirect_uri is not registered")
+		return
+	}
+
+	state := form.Get("state")
+	if form.Get("response_type") != "code" {
+		s.redirectError(c, redirectURI, state, "unsupported_response_type", "response_type must be code")
+		return
+	}
+	if form.Get("code_challenge") == "" || form.Get("code_challenge_method") != model.CodeChallengeS256 {
+		s.redirectError(c, redirectURI, state, "invalid_request", "code_challenge with the S256 method is required")
+		return
+	}
+
+	scopes := model.SplitScopes(form.Get("scope"))
+	if !client.AllowsScopes(scopes...) {
+		s.redirectError(c, redirectURI, state, "invalid_scope", "scope is not allowed for the client")
+		return
+	}
+
+	// The client never gets more than the user could do, nor more than the
+	// presented token was granted. The OpenID Connect scopes only describe
+	// the user the token belongs to, so the token need not carry them.
+	u, err := s.store.User().Find(details.UserID)
+	if err != nil {
+		s.redirectError(c, redirectURI, state, "access_denied", errUnknownUser.Error())
+		return
+	}
+	if _, err := userStatusError(u); err != nil {
+		s.redirectError(c, redirectURI, state, "access_denied", err.Error())
+		return
+	}
+	scopes = model.FilterScopes(scopes, u.GrantableScopes())
+	scopes = model.FilterScopes(scopes, append(append([]string{}, details.Scopes...), model.OIDCScopes...))
+
+	code, err := randomToken()
+	if err != nil {
+		s.redirectError(c, redirectURI, state, "server_error", "")
+		return
+	}
+
+	authTime := details.AuthTime
+	if authTime == 0 {
+		authTime = time.Now().Unix()
+	}
+
+	if err := s.store.AuthorizationCode().Create(&model.AuthorizationCode{
+		CodeHash:            model.HashToken(code),
+		ClientID:            client.ID,
+		UserID:              details.UserID,
+		RedirectURI:         redirectURI,
+		Scopes:              scopes,
+		CodeChallenge:       form.Get("code_challenge"),
+		CodeChallengeMethod: model.CodeChallengeS256,
+		Nonce:               form.Get("nonce"),
+		AuthTime:            authTime,
+		ExpiresAt:           time.Now().Add(s.config.OAuth.CodeTTL.Duration),
+	}); err != nil {
+		s.redirectError(c, redirectURI, state, "server_error", "")
+		return
+	}
+
+	query := url.Values{"code": {code}}
+	if state != "" {
+		query.Set("state", state)
+	}
+	c.Redirect(http.StatusFound, withQuery(redirectURI, query))
+}
+
+// HandleToken implements the token endpoint.
+func (s *server) HandleToken(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	client, ok := s.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	grantType := c.Request.PostForm.Get("grant_type")
+	if !client.AllowsGrantType(grantType) {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "unauthorized_client", "grant_type is not allowed for the client")
+		return
+	}
+
+	switch grantType {
+	case model.GrantTypeAuthorizationCode:
+		s.handleAuthorizationCodeGrant(c, client)
+	case model.GrantTypeClientCredentials:
+		s.handleClientCredentialsGrant(c, client)
+	default:
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+// authenticateClient identifies the client with HTTP Basic authentication
+// or the client_id and client_secret form parameters. Public clients only
+// send their client_id. When authentication fails the error response is
+// written and false is returned.
+func (s *server) authenticateClient(c *gin.Context) (*model.Client, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes the credentials.
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = c.Request.PostForm.Get("client_id")
+		secret = c.Request.PostForm.Get("client_secret")
+	}
+
+	client, err := s.store.Client().Find(clientID)
+	if err == nil && (!client.Confidential() || client.CompareSecret(secret)) {
+		return client, true
+	}
+
+	if basic {
+		c.Header("WWW-Authenticate", `Basic realm="token"`)
+	}
+	s.oauthError(c.Writer, c.Request, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+
+	return nil, false
+}
+
+func (s *server) handleAuthorizationCodeGrant(c *gin.Context, client *model.Client) {
+	form := c.Request.PostForm
+
+	code, err := s.store.AuthorizationCode().Consume(model.HashToken(form.Get("code")))
+	if err == store.ErrRecordNotFound {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_grant", "unknown or used code")
+		return
+	}
+	if err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	if code.Expired() {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_grant", "code expired")
+		return
+	}
+	if code.ClientID != client.ID || code.RedirectURI != form.Get("redirect_uri") {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_grant", "client_id or redirect_uri mismatch")
+		return
+	}
+	if !code.VerifyCodeVerifier(form.Get("code_verifier")) {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_grant", "code_verifier mismatch")
+		return
+	}
+
+	u, err := s.store.User().Find(code.UserID)
+	if err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_grant", errUnknownUser.Error())
+		return
+	}
+	if _, err := userStatusError(u); err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+
+	// The code was issued to this client and exchanged by it.
+	ts, err := s.Create(&model.TokenRequest{
+		UserID:              code.UserID,
+		ClientID:            code.ClientID,
+		ClientAuthenticated: true,
+		Roles:               u.Roles,
+		Scopes:              code.Scopes,
+		AuthTime:            code.AuthTime,
+	})
+	if err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	if err := s.store.Token().CreateAuth(code.UserID, ts); err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	for _, scope := range code.Scopes {
+		if scope == model.ScopeOpenID {
+			ts.IDToken, err = s.createIDToken(code, u, ts.AccessToken, ts.AtExpires)
+			if err != nil {
+				s.oauthError(c.Writer, c.Request, http.StatusInternalServerError, "server_error", "")
+				return
+			}
+		}
+	}
+
+	s.tokenResponse(c.Writer, c.Request, ts, code.Scopes)
+}
+
+// handleClientCredentialsGrant issues an access token whose subject is the
+// client itself. No refresh token is issued, the client authenticates again
+// instead.
+func (s *server) handleClientCredentialsGrant(c *gin.Context, client *model.Client) {
+	if !client.Confidential() {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "unauthorized_client", "the grant requires a confidential client")
+		return
+	}
+
+	scopes := model.SplitScopes(c.Request.PostForm.Get("scope"))
+	if len(scopes) == 0 {
+		scopes = client.AllowedScopes
+	}
+	if !client.AllowsScopes(scopes...) {
+		s.oauthError(c.Writer, c.Request, http.StatusBadRequest, "invalid_scope", "scope is not allowed for the client")
+		return
+	}
+
+	ts, err := s.Create(&model.TokenRequest{
+		ClientID:   client.ID,
+		Scopes:     scopes,
+		AccessTTL:  time.Duration(client.TokenTTL) * time.Second,
+		AccessOnly: true,
+	})
+	if err != nil {
+		s.oauthError(c.Writer, c.Request, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	s.tokenResponse(c.Writer, c.Request, ts, scopes)
+}
+
+// tokenResponse writes a successful token endpoint response as defined by
+// RFC 6749 section 5.1.
+func (s *server) tokenResponse(w http.ResponseWriter, r *http.Request, ts *model.TokenDetails, scopes []string) {
+	res := map[string]interface{}{
+		"access_token": ts.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   ts.AtExpires - time.Now().Unix(),
+	}
+	if ts.RefreshToken != "" {
+		res["refresh_token"] = ts.RefreshToken
+	}
+	if ts.IDToken != "" {
+		res["id_token"] = ts.IDToken
+	}
+	if len(scopes) > 0 {
+		res["scope"] = model.JoinScopes(scopes)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	s.respond(w, r, http.StatusOK, res)
+}
+
+func (s *server) oauthError(w http.ResponseWriter, r *http.Request, status int, code string, description string) {
+	res := map[string]string{"error": code}
+	if description != "" {
+		res["error_description"] = description
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	s.respond(w, r, status, res)
+}
+
+func (s *server) redirectError(c *gin.Context, redirectURI string, state string, code string, description string) {
+	query := url.Values{"error": {code}}
+	if description != "" {
+		query.Set("error_description", description)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	c.Redirect(http.StatusFound, withQuery(redirectURI, query))
+}
+
+// withQuery adds query to the query string already present in rawURL.
+func withQuery(rawURL string, query url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,353 @@
+package apiserver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func TestServer_HandleAuthorize(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	tokens := testOAuthSetup(t, s)
+
+	testCases := []struct {
+		name         string
+		query        url.Values
+		expectedCode int
+		location     string
+	}{
+		{
+			name:         "valid",
+			query:        testAuthorizeQuery(nil),
+			expectedCode: http.StatusFound,
+			location:     "https://app.example.org/callback?code=",
+		},
+		{
+			name:         "unknown client",
+			query:        testAuthorizeQuery(url.Values{"client_id": {"unknown"}}),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unregistered redirect uri",
+			query:        testAuthorizeQuery(url.Values{"redirect_uri": {"https://evil.example.com/callback"}}),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "plain code challenge",
+			query:        testAuthorizeQuery(url.Values{"code_challenge_method": {"plain"}}),
+			expectedCode: http.StatusFound,
+			location:     "https://app.example.org/callback?error=invalid_request",
+		},
+		{
+			name:         "scope not allowed",
+			query:        testAuthorizeQuery(url.Values{"scope": {"admin"}}),
+			expectedCode: http.StatusFound,
+			location:     "https://app.example.org/callback?error=invalid_scope",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/authorize?"+tc.query.Encode(), nil)
+			req.Header.Set("Authorization", "Bearer "+tokens["access_token"])
+			s.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.location != "" {
+				assert.True(t, strings.HasPrefix(rec.Header().Get("Location"), tc.location), rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestServer_HandleAuthorize_TokenScopes(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	testOAuthSetup(t, s)
+	u, err := s.store.User().FindByEmail("user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The user may be granted orders:read, but the token presented may not.
+	narrow, err := s.Create(&model.TokenRequest{UserID: u.ID.Hex(), Scopes: []string{"profile"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := testAuthorize(t, s, narrow.AccessToken)
+	rec := testTokenRequest(s, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {"spa"},
+		"redirect_uri":  {"https://app.example.org/callback"},
+		"code_verifier": {testCodeVerifier},
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	res := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	assert.Nil(t, res["scope"])
+}
+
+func TestServer_HandleToken_AuthorizationCode(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	tokens := testOAuthSetup(t, s)
+
+	code := testAuthorize(t, s, tokens["access_token"])
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {"spa"},
+		"redirect_uri":  {"https://app.example.org/callback"},
+		"code_verifier": {"wrong-verifier-wrong-verifier-wrong-verifier"},
+	}
+
+	rec := testTokenRequest(s, form)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	code = testAuthorize(t, s, tokens["access_token"])
+	form.Set("code", code)
+	form.Set("code_verifier", testCodeVerifier)
+
+	rec = testTokenRequest(s, form)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	res := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "Bearer", res["token_type"])
+	assert.Equal(t, "orders:read", res["scope"])
+	assert.NotEmpty(t, res["access_token"])
+	assert.NotEmpty(t, res["refresh_token"])
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+res["access_token"].(string))
+	claims, err := s.VerifyToken(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders:read", claims.Scope)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/Refresh", nil)
+	req.Header.Set(refreshTokenHeader, res["refresh_token"].(string))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	refreshed := map[string]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&refreshed)
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshed["access_token"])
+	claims, err = s.VerifyToken(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders:read", claims.Scope)
+
+	rec = testTokenRequest(s, form)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	res = map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "invalid_grant", res["error"])
+}
+
+func testOAuthSetup(t *testing.T, s *server) map[string]string {
+	t.Helper()
+
+	if err := s.store.Client().Create(&model.Client{
+		ID:            "spa",
+		RedirectURIs:  []string{"https://app.example.org/callback"},
+		AllowedScopes: []string{"orders:read", "orders:write"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	u := model.TestUser(t)
+	u.Scopes = []string{"orders:read", "admin"}
+	tokens, _ := testLoginUser(t, s, u)
+
+	return tokens
+}
+
+func testAuthorizeQuery(override url.Values) url.Values {
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://app.example.org/callback"},
+		"scope":                 {"orders:read orders:write"},
+		"state":                 {"xyz"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	for k, v := range override {
+		query[k] = v
+	}
+	return query
+}
+
+func testAuthorize(t *testing.T, s *server, accessToken string) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/authorize?"+testAuthorizeQuery(nil).Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	s.ServeHTTP(rec, req)
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || location.Query().Get("code") == "" {
+		t.Fatalf("authorize failed: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	return location.Query().Get("code")
+}
+
+func testTokenRequest(s *server, form url.Values) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_ClientLifetimes(t *testing.T) {
+	config := NewConfig()
+	config.Tokens.Clients = map[string]TokenTTL{"spa": {AccessTTL: Duration{time.Hour}}}
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), config)
+	testOAuthSetup(t, s)
+
+	accessTTL := func(accessToken string) int64 {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		claims, err := s.VerifyToken(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claims.ExpiresAt - claims.IssuedAt
+	}
+
+	// Naming the client in a login does not get its lifetimes.
+	rec := testJSONRequest(s, "/Login", "", map[string]string{"email": "user@example.org", "password": "password", "client_id": "spa"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	tokens := map[string]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&tokens)
+	assert.Equal(t, int64(15*60), accessTTL(tokens["access_token"]))
+
+	// The client gets them through the authorization code grant, and keeps
+	// them over refreshes.
+	rec = testTokenRequest(s, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {testAuthorize(t, s, tokens["access_token"])},
+		"client_id":     {"spa"},
+		"redirect_uri":  {"https://app.example.org/callback"},
+		"code_verifier": {testCodeVerifier},
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	res := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, int64(60*60), accessTTL(res["access_token"].(string)))
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+	req.Header.Set(refreshTokenHeader, res["refresh_token"].(string))
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	refreshed := map[string]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&refreshed)
+	assert.Equal(t, int64(60*60), accessTTL(refreshed["access_token"]))
+}
+
+func TestServer_HandleToken_ClientCredentials(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	testOAuthSetup(t, s)
+
+	secret := "0123456789abcdef0123456789abcdef"
+	if err := s.store.Client().Create(&model.Client{
+		ID:            "billing-job",
+		Secret:        secret,
+		AllowedScopes: []string{"orders:read", "invoices:write"},
+		GrantTypes:    []string{model.GrantTypeClientCredentials},
+		TokenTTL:      300,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		form         url.Values
+		basic        []string
+		expectedCode int
+		errorCode    string
+	}{
+		{
+			name:         "basic auth",
+			form:         url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}},
+			basic:        []string{"billing-job", secret},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "form auth",
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"billing-job"},
+				"client_secret": {secret},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "wrong secret",
+			form:         url.Values{"grant_type": {"client_credentials"}},
+			basic:        []string{"billing-job", "wrong"},
+			expectedCode: http.StatusUnauthorized,
+			errorCode:    "invalid_client",
+		},
+		{
+			name:         "scope not allowed",
+			form:         url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:write"}},
+			basic:        []string{"billing-job", secret},
+			expectedCode: http.StatusBadRequest,
+			errorCode:    "invalid_scope",
+		},
+		{
+			name:         "public client",
+			form:         url.Values{"grant_type": {"client_credentials"}, "client_id": {"spa"}},
+			expectedCode: http.StatusBadRequest,
+			errorCode:    "unauthorized_client",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/token", strings.NewReader(tc.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basic != nil {
+				req.SetBasicAuth(tc.basic[0], tc.basic[1])
+			}
+			s.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+
+			res := map[string]interface{}{}
+			_ = json.NewDecoder(rec.Body).Decode(&res)
+			if tc.errorCode != "" {
+				assert.Equal(t, tc.errorCode, res["error"])
+				return
+			}
+
+			assert.Nil(t, res["refresh_token"])
+			assert.InDelta(t, 300, res["expires_in"], 1)
+
+			req, _ = http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+res["access_token"].(string))
+			claims, err := s.VerifyToken(req)
+			assert.NoError(t, err)
+			assert.Equal(t, "billing-job", claims.Subject)
+			assert.Equal(t, "billing-job", claims.ClientID)
+			assert.Empty(t, claims.UserID)
+		})
+	}
+}
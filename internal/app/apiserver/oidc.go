@@ -0,0 +1,97 @@
+package apiserver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// HandleOpenIDConfiguration serves the OpenID Connect discovery document.
+func (s *server) HandleOpenIDConfiguration(c *gin.Context) {
+	base := s.baseURL(c.Request)
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.config.Tokens.Issuer,
+		"authorization_endpoint":                base + "/authorize",
+		"token_endpoint":                        base + "/token",
+		"userinfo_endpoint":                     base + "/userinfo",
+		"jwks_uri":                              base + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{model.GrantTypeAuthorizationCode, model.GrantTypeClientCredentials},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      model.OIDCScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{model.CodeChallengeS256},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash",
+			"name", "given_name", "family_name", "preferred_username", "email", "email_verified",
+		},
+	})
+}
+
+// HandleJWKS serves the public key ID tokens are signed with.
+func (s *server) HandleJWKS(c *gin.Context) {
+	jwk, err := s.signingKey.jwk()
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{jwk},
+	})
+}
+
+// HandleUserInfo returns the claims about the user released for the scopes
+// of the access token.
+func (s *server) HandleUserInfo(c *gin.Context) {
+	details, _ := s.accessDetails(c)
+
+	u, err := s.store.User().Find(details.UserID)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "token_invalid", errUnknownUser)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, model.UserInfo(u, details.Scopes))
+}
+
+// createIDToken issues the ID token returned next to accessToken.
+func (s *server) createIDToken(code *model.AuthorizationCode, u *model.User, accessToken string, expires int64) (string, error) {
+	now := time.Now()
+	sum := sha256.Sum256([]byte(accessToken))
+
+	claims := &model.IDClaims{
+		RegisteredClaims: model.RegisteredClaims{
+			Issuer:    s.config.Tokens.Issuer,
+			Audience:  model.Audience{code.ClientID},
+			Subject:   code.UserID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expires,
+		},
+		AuthTime:        code.AuthTime,
+		Nonce:           code.Nonce,
+		AccessTokenHash: base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]),
+	}
+	claims.SetEmail(u, code.Scopes)
+
+	return s.signingKey.sign(claims, "JWT")
+}
+
+// baseURL returns the URL the endpoints are published under: the issuer
+// when it is a URL, or else the URL the request was made to.
+func (s *server) baseURL(r *http.Request) string {
+	if u, err := url.Parse(s.config.Tokens.Issuer); err == nil && u.Scheme != "" && u.Host != "" {
+		return strings.TrimSuffix(s.config.Tokens.Issuer, "/")
+	}
+
+	return requestOrigin(r)
+}
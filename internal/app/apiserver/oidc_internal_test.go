@@ -0,0 +1,161 @@
+package apiserver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleOpenIDConfiguration(t *testing.T) {
+	config := NewConfig()
+	config.Tokens.Issuer = "https://auth.example.org"
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), config)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	res := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "https://auth.example.org", res["issuer"])
+	assert.Equal(t, "https://auth.example.org/token", res["token_endpoint"])
+	assert.Equal(t, "https://auth.example.org/.well-known/jwks.json", res["jwks_uri"])
+}
+
+func TestTokensConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		issuer  string
+		isValid bool
+	}{
+		{issuer: "https://auth.example.org", isValid: true},
+		{issuer: "https://auth.example.org/tenant", isValid: true},
+		{issuer: "http://localhost:8080", isValid: true},
+		{issuer: "http://127.0.0.1:8080", isValid: true},
+		{issuer: "go-test-work", isValid: false},
+		{issuer: "", isValid: false},
+		{issuer: "http://auth.example.org", isValid: false},
+		{issuer: "https://auth.example.org?tenant=1", isValid: false},
+		{issuer: "https://auth.example.org#top", isValid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.issuer, func(t *testing.T) {
+			c := NewConfig().Tokens
+			c.Issuer = tc.issuer
+			if tc.isValid {
+				assert.NoError(t, c.validate())
+			} else {
+				assert.Error(t, c.validate())
+			}
+		})
+	}
+}
+
+func TestOIDCConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  OIDCConfig
+		isValid bool
+	}{
+		{name: "key file", config: OIDCConfig{SigningKeyFile: "key.pem"}, isValid: true},
+		{name: "generated key", config: OIDCConfig{GenerateSigningKey: true}, isValid: true},
+		{name: "no key", config: OIDCConfig{}, isValid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.isValid {
+				assert.NoError(t, tc.config.validate())
+			} else {
+				assert.Error(t, tc.config.validate())
+			}
+		})
+	}
+}
+
+func TestServer_OpenIDConnectFlow(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	if err := s.store.Client().Create(&model.Client{
+		ID:            "rp",
+		RedirectURIs:  []string{"https://app.example.org/callback"},
+		AllowedScopes: []string{"openid", "profile", "email"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	u := model.TestUser(t)
+	u.FullName = "Jane Doe"
+	tokens, _ := testLoginUser(t, s, u)
+
+	rec := httptest.NewRecorder()
+	query := testAuthorizeQuery(url.Values{
+		"client_id": {"rp"},
+		"scope":     {"openid profile email"},
+		"nonce":     {"n-0S6_WzA2Mj"},
+	})
+	req, _ := http.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"])
+	s.ServeHTTP(rec, req)
+	location, _ := url.Parse(rec.Header().Get("Location"))
+
+	rec = testTokenRequest(s, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"client_id":     {"rp"},
+		"redirect_uri":  {"https://app.example.org/callback"},
+		"code_verifier": {testCodeVerifier},
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	res := map[string]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+
+	key, _, err := s.signingKey.load()
+	assert.NoError(t, err)
+	claims := &model.IDClaims{}
+	_, err = jwt.ParseWithClaims(res["id_token"], claims, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, u.ID.Hex(), claims.Subject)
+	assert.Equal(t, model.Audience{"rp"}, claims.Audience)
+	assert.Equal(t, "n-0S6_WzA2Mj", claims.Nonce)
+	assert.NotZero(t, claims.AuthTime)
+	sum := sha256.Sum256([]byte(res["access_token"]))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:16]), claims.AccessTokenHash)
+	assert.Equal(t, u.Email, claims.Email)
+	if assert.NotNil(t, claims.EmailVerified) {
+		assert.True(t, *claims.EmailVerified)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+res["access_token"])
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	info := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&info)
+	assert.Equal(t, map[string]interface{}{
+		"sub":            u.ID.Hex(),
+		"name":           "Jane Doe",
+		"email":          u.Email,
+		"email_verified": true,
+	}, info)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"])
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
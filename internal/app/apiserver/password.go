@@ -0,0 +1,176 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// HandlePasswordForgot emails a password reset link. It answers the same
+// whether or not the email is registered, so that it cannot be used to
+// find out which addresses have an account.
+func (s *server) HandlePasswordForgot(c *gin.Context) {
+	type request struct {
+		Email string `json:"email"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := s.store.User().FindByEmail(req.Email)
+	if err == nil {
+		if err := s.sendPasswordReset(u); err != nil {
+			s.logger.Errorf("password reset for %s: %v", u.ID.Hex(), err)
+		}
+	} else if err != store.ErrRecordNotFound {
+		s.logger.Errorf("password reset: %v", err)
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusAccepted, "If the email is registered, a reset link has been sent")
+}
+
+// HandlePasswordReset sets a new password with a reset token and revokes
+// all refresh sessions of the user.
+func (s *server) HandlePasswordReset(c *gin.Context) {
+	type request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	// The token is looked up and the password checked before the token is
+	// used up, so that a rejected password does not spend the link.
+	t, err := s.store.OneTimeToken().Find(model.HashToken(req.Token), model.PurposePasswordReset)
+	if err == store.ErrRecordNotFound {
+		s.errorCode(c.Writer, c.Request, http.StatusBadRequest, "reset_token_invalid", errInvalidResetToken)
+		return
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+	if t.Expired() {
+		s.errorCode(c.Writer, c.Request, http.StatusBadRequest, "reset_token_expired", errResetTokenExpired)
+		return
+	}
+
+	auditSubject(c, t.UserID)
+
+	u, err := s.store.User().Find(t.UserID)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, errUnknownUser)
+		return
+	}
+
+	u.Password = req.Password
+	if err := u.ValidatePassword(s.passwordPolicy); err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if _, err := s.store.OneTimeToken().Consume(t.Hash, model.PurposePasswordReset); err == store.ErrRecordNotFound {
+		s.errorCode(c.Writer, c.Request, http.StatusBadRequest, "reset_token_invalid", errInvalidResetToken)
+		return
+	} else if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := u.EncryptPassword(s.passwordHasher); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+	u.Sanitize()
+	if err := s.store.User().UpdatePassword(u.ID.Hex(), u.EncryptedPassword); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := s.store.OneTimeToken().DeleteAll(t.UserID, model.PurposePasswordReset); err != nil {
+		s.logger.Errorf("password reset for %s: %v", t.UserID, err)
+	}
+	if err := s.store.Token().DeleteTokens(&model.AccessDetails{UserID: t.UserID}); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, "Password has been reset")
+}
+
+// comparePassword checks password against the hash of u. When there is no
+// such user it is checked against a dummy hash instead, so that the time
+// taken does not tell whether the email is registered.
+func (s *server) comparePassword(u *model.User, password string) bool {
+	if u == nil {
+		s.dummyHashOnce.Do(func() {
+			hash, err := s.passwordHasher.Hash("dummy password")
+			if err != nil {
+				s.logger.Errorf("dummy password hash: %v", err)
+			}
+			s.dummyHash = hash
+		})
+		_, _ = s.passwordHasher.Verify(s.dummyHash, password)
+		return false
+	}
+
+	return u.ComparePassword(s.passwordHasher, password)
+}
+
+// rehashPassword replaces an outdated password hash of a user who has just
+// logged in with password. Failing to do so does not fail the login.
+func (s *server) rehashPassword(u *model.User, password string) {
+	if !u.PasswordNeedsRehash(s.passwordHasher) {
+		return
+	}
+
+	u.Password = password
+	if err := u.EncryptPassword(s.passwordHasher); err != nil {
+		s.logger.Errorf("password rehash for %s: %v", u.ID.Hex(), err)
+		return
+	}
+	u.Sanitize()
+
+	if err := s.store.User().UpdatePassword(u.ID.Hex(), u.EncryptedPassword); err != nil {
+		s.logger.Errorf("password rehash for %s: %v", u.ID.Hex(), err)
+	}
+}
+
+func (s *server) sendPasswordReset(u *model.User) error {
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.OneTimeToken().Create(&model.OneTimeToken{
+		Hash:      model.HashToken(token),
+		Purpose:   model.PurposePasswordReset,
+		UserID:    u.ID.Hex(),
+		ExpiresAt: time.Now().Add(s.config.PasswordReset.TokenTTL.Duration),
+	}); err != nil {
+		return err
+	}
+
+	link := withQuery(s.config.PasswordReset.URL, url.Values{"token": {token}})
+
+	return s.mailer.Send(&mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Follow this link to choose a new password:\n\n%s\n\nThe link expires in %s. If you did not ask for it, ignore this email.",
+			link, s.config.PasswordReset.TokenTTL.Duration,
+		),
+	})
+}
@@ -0,0 +1,175 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testMailer struct {
+	messages []*mailer.Message
+}
+
+func (m *testMailer) Send(msg *mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// testMailedToken returns the value of the query parameter name in the
+// last link that was mailed.
+func testMailedToken(t *testing.T, m *testMailer, name string) string {
+	t.Helper()
+	if !assert.NotEmpty(t, m.messages) {
+		return ""
+	}
+	link := regexp.MustCompile(`https?://\S+`).FindString(m.messages[len(m.messages)-1].Body)
+	u, err := url.Parse(link)
+	assert.NoError(t, err)
+	return u.Query().Get(name)
+}
+
+func TestServer_PasswordReset(t *testing.T) {
+	m := &testMailer{}
+	s := newServer(teststore.New(), m, NewConfig())
+	u := model.TestUser(t)
+	tokens, _ := testLoginUser(t, s, u)
+
+	rec := testJSONRequest(s, "/password/forgot", "", map[string]string{"email": "unknown@example.org"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, m.messages)
+
+	rec = testJSONRequest(s, "/password/forgot", "", map[string]string{"email": u.Email})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Len(t, m.messages, 1)
+	assert.Equal(t, u.Email, m.messages[0].To)
+	token := testMailedToken(t, m, "token")
+	assert.NotEmpty(t, token)
+
+	testCases := []struct {
+		name         string
+		payload      map[string]string
+		expectedCode int
+	}{
+		{
+			name:         "short password",
+			payload:      map[string]string{"token": token, "password": "123"},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "password like the email",
+			payload:      map[string]string{"token": token, "password": "user-password"},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "invalid token",
+			payload:      map[string]string{"token": "invalid", "password": "new-password"},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "valid",
+			payload:      map[string]string{"token": token, "password": "new-password"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "reused token",
+			payload:      map[string]string{"token": token, "password": "other-password"},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := testJSONRequest(s, "/password/reset", "", tc.payload)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+
+	updated, err := s.store.User().Find(u.ID.Hex())
+	assert.NoError(t, err)
+	assert.True(t, updated.ComparePassword(s.passwordHasher, "new-password"))
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+	req.Header.Set(refreshTokenHeader, tokens["refresh_token"])
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_PasswordReset_Expired(t *testing.T) {
+	m := &testMailer{}
+	config := NewConfig()
+	config.PasswordReset.TokenTTL = Duration{-time.Minute}
+	s := newServer(teststore.New(), m, config)
+	u := model.TestUser(t)
+	testLoginUser(t, s, u)
+
+	testJSONRequest(s, "/password/forgot", "", map[string]string{"email": u.Email})
+	rec := testJSONRequest(s, "/password/reset", "", map[string]string{
+		"token":    testMailedToken(t, m, "token"),
+		"password": "new-password",
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "reset_token_expired")
+}
+
+func TestServer_HandleSessionsCreate_Password(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+	u := model.TestUser(t)
+	assert.NoError(t, s.store.User().Create(u))
+
+	// A hash imported from the old system.
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	u.EncryptedPassword = string(legacy)
+	assert.NoError(t, s.store.User().Update(u))
+
+	rec := testJSONRequest(s, "/Login", "", map[string]string{"email": u.Email, "password": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid_credentials")
+
+	rec = testJSONRequest(s, "/Login", "", map[string]string{"email": u.Email, "password": "password"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	updated, _ := s.store.User().Find(u.ID.Hex())
+	assert.True(t, strings.HasPrefix(updated.EncryptedPassword, "$argon2id$"))
+	assert.True(t, updated.ComparePassword(s.passwordHasher, "password"))
+}
+
+func TestServer_PasswordConfig(t *testing.T) {
+	strict := NewConfig()
+	strict.EmailVerification.Required = false
+	strict.Password.MinLength = 12
+	strict.Password.Argon2.Memory = 1024
+	s := newServer(teststore.New(), &testMailer{}, strict)
+	other := newServer(teststore.New(), &testMailer{}, NewConfig())
+
+	rec := testJSONRequest(s, "/Register", "", map[string]string{"email": "new@example.org", "password": "password"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = testJSONRequest(other, "/Register", "", map[string]string{"email": "new@example.org", "password": "password"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = testJSONRequest(s, "/Register", "", map[string]string{"email": "new@example.org", "password": "long-password"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	u, err := s.store.User().FindByEmail("new@example.org")
+	assert.NoError(t, err)
+	assert.Contains(t, u.EncryptedPassword, "$m=1024,")
+}
+
+func TestServer_HandleSessionsCreate_UnknownEmail(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+
+	rec := testJSONRequest(s, "/Login", "", map[string]string{"email": "unknown@example.org", "password": "password"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid_credentials")
+	// The password was still checked against a hash.
+	assert.True(t, strings.HasPrefix(s.dummyHash, "$argon2id$"))
+}
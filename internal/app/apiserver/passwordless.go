@@ -0,0 +1,305 @@
+package apiserver
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/twinj/uuid"
+)
+
+const (
+	magicLinkType   = "magic+jwt"
+	loginCodeDigits = 6
+
+	passwordlessLink = "link"
+	passwordlessCode = "code"
+)
+
+var errUnknownPasswordlessMethod = errors.New("method must be link or code")
+
+// magicLinkPage asks the user to confirm the login, so that email scanners
+// and prefetchers following the link do not use it up.
+var magicLinkPage = template.Must(template.New("magic-link").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Log in</title>
+</head>
+<body>
+<form method="post">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Log in</button>
+</form>
+</body>
+</html>
+`))
+
+// HandlePasswordlessStart emails a magic link or a login code. Like
+// /password/forgot it answers the same whether or not the email is
+// registered, but it is rate limited per address and per client IP.
+func (s *server) HandlePasswordlessStart(c *gin.Context) {
+	type request struct {
+		Email    string `json:"email"`
+		Method   string `json:"method"`
+		ClientID string `json:"client_id"`
+	}
+	req := &request{Method: passwordlessLink}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	var send func(*model.User, string) error
+	switch req.Method {
+	case passwordlessLink:
+		send = s.sendMagicLink
+	case passwordlessCode:
+		send = s.sendLoginCode
+	default:
+		s.error(c.Writer, c.Request, http.StatusBadRequest, errUnknownPasswordlessMethod)
+		return
+	}
+
+	now := time.Now()
+	for _, limit := range []struct {
+		limiter *rateLimiter
+		key     string
+	}{
+		{limiter: s.sendIPLimiter, key: c.ClientIP()},
+		{limiter: s.sendLimiter, key: strings.ToLower(req.Email)},
+	} {
+		if wait, ok := limit.limiter.allow(limit.key, now); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			s.errorCode(c.Writer, c.Request, http.StatusTooManyRequests, "rate_limited", errRateLimited)
+			return
+		}
+	}
+
+	u, err := s.store.User().FindByEmail(req.Email)
+	if err == nil {
+		if err := send(u, req.ClientID); err != nil {
+			s.logger.Errorf("passwordless login for %s: %v", u.ID.Hex(), err)
+		}
+	} else if err != store.ErrRecordNotFound {
+		s.logger.Errorf("passwordless login: %v", err)
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusAccepted, "If the email is registered, a login "+req.Method+" has been sent")
+}
+
+// HandlePasswordlessConfirm is where the magic link leads. It only checks
+// the link and shows a page that posts it back to /passwordless/verify, so
+// that merely opening the link logs nobody in.
+func (s *server) HandlePasswordlessConfirm(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := s.verifyMagicLink(token); err != nil {
+		s.tokenError(c.Writer, c.Request, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := magicLinkPage.Execute(c.Writer, token); err != nil {
+		s.logger.Errorf("magic link page: %v", err)
+	}
+}
+
+// HandlePasswordlessVerify exchanges a magic link token, or an email and a
+// login code, for a token pair as /Login does. The magic link token may
+// also come from the form of the confirmation page.
+func (s *server) HandlePasswordlessVerify(c *gin.Context) {
+	type request struct {
+		Token string `json:"token"`
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	req := &request{Token: c.PostForm("token")}
+	if req.Token == "" {
+		if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+			s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var t *model.OneTimeToken
+	if req.Token != "" {
+		claims, err := s.verifyMagicLink(req.Token)
+		if err != nil {
+			s.tokenError(c.Writer, c.Request, err)
+			return
+		}
+
+		t, err = s.consumeLoginToken(c, model.HashToken(claims.ID), model.PurposeMagicLink)
+		if err != nil {
+			return
+		}
+	} else {
+		if _, ok := s.codeLimiter.allow(strings.ToLower(req.Email), time.Now()); !ok {
+			s.errorCode(c.Writer, c.Request, http.StatusTooManyRequests, "too_many_attempts", errTooManyAttempts)
+			return
+		}
+
+		u, err := s.store.User().FindByEmail(req.Email)
+		if err != nil {
+			s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "login_token_invalid", errInvalidLoginToken)
+			return
+		}
+
+		t, err = s.consumeLoginToken(c, model.HashToken(u.ID.Hex()+":"+req.Code), model.PurposeLoginCode)
+		if err != nil {
+			return
+		}
+	}
+
+	auditSubject(c, t.UserID)
+
+	u, err := s.store.User().Find(t.UserID)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnauthorized, errUnknownUser)
+		return
+	}
+
+	// Receiving the link or the code proves that the user owns the email.
+	if !u.EmailVerified {
+		u.EmailVerified = true
+		if u.Status == model.UserStatusPendingVerification {
+			u.Status = model.UserStatusActive
+		}
+		if err := s.store.User().MarkEmailVerified(t.UserID); err != nil {
+			s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	s.completeLogin(c, u, t.ClientID)
+}
+
+// verifyMagicLink checks the signature and the claims of a magic link
+// token. It does not use the link up.
+func (s *server) verifyMagicLink(token string) (*model.ChallengeClaims, error) {
+	claims := &model.ChallengeClaims{}
+	if err := s.signingKey.verify(token, magicLinkType, claims); err != nil {
+		return nil, err
+	}
+	if err := claims.Verify(time.Now(), s.config.Tokens.Leeway.Duration, s.config.Tokens.Issuer, s.config.Tokens.Audiences); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// consumeLoginToken uses up a magic link or a login code. On failure it
+// has already responded.
+func (s *server) consumeLoginToken(c *gin.Context, hash string, purpose string) (*model.OneTimeToken, error) {
+	t, err := s.store.OneTimeToken().Consume(hash, purpose)
+	if err == store.ErrRecordNotFound {
+		s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "login_token_invalid", errInvalidLoginToken)
+		return nil, err
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return nil, err
+	}
+	if t.Expired() {
+		s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "login_token_expired", errLoginTokenExpired)
+		return nil, errLoginTokenExpired
+	}
+
+	return t, nil
+}
+
+func (s *server) sendMagicLink(u *model.User, clientID string) error {
+	now := time.Now()
+	expires := now.Add(s.config.Passwordless.LinkTTL.Duration)
+	jti := uuid.NewV4().String()
+
+	token, err := s.signingKey.sign(&model.ChallengeClaims{
+		RegisteredClaims: s.registeredClaims(u.ID.Hex(), jti, now, expires.Unix()),
+		UserID:           u.ID.Hex(),
+		ClientID:         clientID,
+	}, magicLinkType)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.OneTimeToken().Create(&model.OneTimeToken{
+		Hash:      model.HashToken(jti),
+		Purpose:   model.PurposeMagicLink,
+		UserID:    u.ID.Hex(),
+		ClientID:  clientID,
+		ExpiresAt: expires,
+	}); err != nil {
+		return err
+	}
+
+	link := withQuery(s.config.Passwordless.LinkURL, url.Values{"token": {token}})
+
+	return s.mailer.Send(&mailer.Message{
+		To:      u.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf(
+			"Follow this link to log in:\n\n%s\n\nThe link expires in %s and works once. If you did not ask for it, ignore this email.",
+			link, s.config.Passwordless.LinkTTL.Duration,
+		),
+	})
+}
+
+// sendLoginCode replaces any pending login code of the user with a new one
+// and mails it. Codes are short, so they are stored hashed together with
+// the user id and only accepted along with the email.
+func (s *server) sendLoginCode(u *model.User, clientID string) error {
+	code, err := randomDigits(loginCodeDigits)
+	if err != nil {
+		return err
+	}
+
+	userID := u.ID.Hex()
+	if err := s.store.OneTimeToken().DeleteAll(userID, model.PurposeLoginCode); err != nil {
+		return err
+	}
+	if err := s.store.OneTimeToken().Create(&model.OneTimeToken{
+		Hash:      model.HashToken(userID + ":" + code),
+		Purpose:   model.PurposeLoginCode,
+		UserID:    userID,
+		ClientID:  clientID,
+		ExpiresAt: time.Now().Add(s.config.Passwordless.CodeTTL.Duration),
+	}); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(&mailer.Message{
+		To:      u.Email,
+		Subject: "Your login code",
+		Body: fmt.Sprintf(
+			"Your login code is %s\n\nIt expires in %s and works once. If you did not ask for it, ignore this email.",
+			code, s.config.Passwordless.CodeTTL.Duration,
+		),
+	})
+}
+
+func randomDigits(n int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", n, v), nil
+}
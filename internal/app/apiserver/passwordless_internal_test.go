@@ -0,0 +1,139 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PasswordlessLink(t *testing.T) {
+	m := &testMailer{}
+	s := newServer(teststore.New(), m, NewConfig())
+	u := model.TestUser(t)
+	assert.NoError(t, s.store.User().Create(u))
+
+	rec := testJSONRequest(s, "/passwordless/start", "", map[string]string{"email": u.Email, "method": "sms"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = testJSONRequest(s, "/passwordless/start", "", map[string]string{"email": u.Email, "client_id": "web"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	token := testMailedToken(t, m, "token")
+
+	// Opening the link, as a mail scanner would, only shows the
+	// confirmation page.
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/passwordless/verify?token="+token, nil)
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rec.Body.String(), `<form method="post">`)
+		assert.Contains(t, rec.Body.String(), token)
+		assert.NotContains(t, rec.Body.String(), "refresh_token")
+	}
+
+	verify := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/passwordless/verify", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	rec = verify()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "refresh_token")
+	rec = verify()
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "login_token_invalid")
+
+	rec = testJSONRequest(s, "/passwordless/verify", "", map[string]string{"token": token + "x"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "token_signature_invalid")
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/passwordless/verify?token="+token+"x", nil)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_PasswordlessStart_RateLimit(t *testing.T) {
+	m := &testMailer{}
+	config := NewConfig()
+	config.Passwordless.SendLimitPerIP = 3
+	s := newServer(teststore.New(), m, config)
+	u := model.TestUser(t)
+	assert.NoError(t, s.store.User().Create(u))
+
+	start := func(email string) *httptest.ResponseRecorder {
+		return testJSONRequest(s, "/passwordless/start", "", map[string]string{"email": email})
+	}
+
+	rec := start(u.Email)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = start(strings.ToUpper(u.Email))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	assert.Len(t, m.messages, 1)
+
+	rec = start("other@example.org")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = start("unknown@example.org")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestServer_PasswordlessCode(t *testing.T) {
+	m := &testMailer{}
+	config := NewConfig()
+	config.Passwordless.SendInterval = Duration{}
+	s := newServer(teststore.New(), m, config)
+	u := model.TestUser(t)
+	assert.NoError(t, s.store.User().Create(u))
+
+	start := func() string {
+		rec := testJSONRequest(s, "/passwordless/start", "", map[string]string{"email": u.Email, "method": "code"})
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		return regexp.MustCompile(`\b\d{6}\b`).FindString(m.messages[len(m.messages)-1].Body)
+	}
+	verify := func(code string) *httptest.ResponseRecorder {
+		return testJSONRequest(s, "/passwordless/verify", "", map[string]string{"email": u.Email, "code": code})
+	}
+
+	code := start()
+	assert.Len(t, code, loginCodeDigits)
+	rec := verify(code)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = verify(code)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	code = start()
+	for i := 3; i < s.config.Passwordless.MaxAttempts; i++ {
+		rec = verify("wrong")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	rec = verify(code)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = verify(code)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestServer_PasswordlessCode_Expired(t *testing.T) {
+	m := &testMailer{}
+	config := NewConfig()
+	config.Passwordless.CodeTTL = Duration{-time.Minute}
+	s := newServer(teststore.New(), m, config)
+	u := model.TestUser(t)
+	assert.NoError(t, s.store.User().Create(u))
+
+	testJSONRequest(s, "/passwordless/start", "", map[string]string{"email": u.Email, "method": "code"})
+	code := regexp.MustCompile(`\b\d{6}\b`).FindString(m.messages[0].Body)
+	rec := testJSONRequest(s, "/passwordless/verify", "", map[string]string{"email": u.Email, "code": code})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "login_token_expired")
+}
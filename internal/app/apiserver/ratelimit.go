@@ -0,0 +1,69 @@
+package apiserver
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter limits how often an action is taken per key: at most limit
+// times within window, and no sooner than interval after the previous
+// time. The state is kept in memory, so each instance limits on its own;
+// keys without a hit in the last window are dropped once per window.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	window   time.Duration
+	limit    int
+	hits     map[string][]time.Time
+	swept    time.Time
+}
+
+func newRateLimiter(interval, window time.Duration, limit int) *rateLimiter {
+	return &rateLimiter{
+		interval: interval,
+		window:   window,
+		limit:    limit,
+		hits:     make(map[string][]time.Time),
+	}
+}
+
+// allow records an attempt for key at now if it is within the limits.
+// Otherwise it returns how long to wait before the next attempt.
+func (l *rateLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) >= l.window {
+		l.sweep(now)
+	}
+
+	hits := l.hits[key][:0]
+	for _, hit := range l.hits[key] {
+		if now.Sub(hit) < l.window {
+			hits = append(hits, hit)
+		}
+	}
+
+	if n := len(hits); n > 0 && now.Sub(hits[n-1]) < l.interval {
+		l.hits[key] = hits
+		return l.interval - now.Sub(hits[n-1]), false
+	}
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return l.window - now.Sub(hits[0]), false
+	}
+
+	l.hits[key] = append(hits, now)
+
+	return 0, true
+}
+
+// sweep deletes the keys whose last hit is older than the window.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, hits := range l.hits {
+		if len(hits) == 0 || now.Sub(hits[len(hits)-1]) >= l.window {
+			delete(l.hits, key)
+		}
+	}
+	l.swept = now
+}
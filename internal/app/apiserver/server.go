@@ -1,32 +1,112 @@
 package apiserver
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
 	"github.com/psihachina/go-test-work.git/internal/app/model"
 	"github.com/psihachina/go-test-work.git/internal/app/store"
 	"github.com/sirupsen/logrus"
 	"github.com/twinj/uuid"
 )
 
+var (
+	errUntrustedOrigin    = errors.New("untrusted origin")
+	errCSRFTokenMismatch  = errors.New("csrf token mismatch")
+	errSessionExpired     = errors.New("session expired")
+	errUnknownUser        = errors.New("unknown user")
+	errInsufficientScope  = errors.New("insufficient scope")
+	errInsufficientRole   = errors.New("insufficient role")
+	errMFAEnabled         = errors.New("two-factor authentication is already enabled")
+	errMFANotEnrolled     = errors.New("two-factor authentication enrollment not started")
+	errInvalidMFACode     = errors.New("invalid two-factor authentication code")
+	errMFACodeReused      = errors.New("two-factor authentication code already used")
+	errInvalidChallenge   = errors.New("invalid or used two-factor authentication challenge")
+	errInvalidResetToken  = errors.New("invalid or used password reset token")
+	errResetTokenExpired  = errors.New("password reset token expired")
+	errInvalidCredentials = errors.New("invalid email or password")
+	errMissingCredentials = errors.New("email and password are required")
+	errEmailNotVerified   = errors.New("email is not verified")
+	errInvalidVerifyToken = errors.New("invalid or used email verification token")
+	errVerifyTokenExpired = errors.New("email verification token expired")
+	errInvalidLoginToken  = errors.New("invalid or used login link or code")
+	errLoginTokenExpired  = errors.New("login link or code expired")
+	errTooManyAttempts    = errors.New("too many attempts")
+	errAccountDisabled    = errors.New("account is disabled")
+	errAccountLocked      = errors.New("account is locked")
+	errAccountPending     = errors.New("account is pending email verification")
+	errAccountStatus      = errors.New("account is not active")
+	errImpersonating      = errors.New("cannot impersonate while impersonating")
+	errImpersonationToken = errors.New("not allowed with an impersonation token")
+	errImpersonationActor = errors.New("impersonation requires a user token")
+	errRateLimited        = errors.New("too many requests")
+)
+
 type server struct {
-	router *gin.Engine
-	logger *logrus.Logger
-	store  store.Store
+	router         *gin.Engine
+	logger         *logrus.Logger
+	store          store.Store
+	mailer         mailer.Mailer
+	config         *Config
+	passwordHasher model.PasswordHasher
+	passwordPolicy *model.PasswordPolicy
+	dummyHash      string
+	dummyHashOnce  sync.Once
+	signingKey     *signingKey
+	resendLimiter  *rateLimiter
+	codeLimiter    *rateLimiter
+	sendLimiter    *rateLimiter
+	sendIPLimiter  *rateLimiter
+	auditChain     *auditChain
+	webhookClient  *http.Client
 }
 
-func newServer(store store.Store) *server {
+func newServer(store store.Store, mailer mailer.Mailer, config *Config) *server {
 	s := &server{
-		router: gin.Default(),
-		logger: logrus.New(),
-		store:  store,
+		router:         gin.Default(),
+		logger:         logrus.New(),
+		store:          store,
+		mailer:         mailer,
+		config:         config,
+		passwordHasher: model.NewPasswordHasher(config.Password.Argon2.params()),
+		passwordPolicy: config.Password.policy(),
+		signingKey: &signingKey{
+			path: config.OIDC.SigningKeyFile,
+		},
+		resendLimiter: newRateLimiter(
+			config.EmailVerification.ResendInterval.Duration,
+			config.EmailVerification.ResendWindow.Duration,
+			config.EmailVerification.ResendLimit,
+		),
+		codeLimiter: newRateLimiter(
+			0,
+			config.Passwordless.CodeTTL.Duration,
+			config.Passwordless.MaxAttempts,
+		),
+		sendLimiter: newRateLimiter(
+			config.Passwordless.SendInterval.Duration,
+			config.Passwordless.SendWindow.Duration,
+			config.Passwordless.SendLimit,
+		),
+		sendIPLimiter: newRateLimiter(
+			0,
+			config.Passwordless.SendWindow.Duration,
+			config.Passwordless.SendLimitPerIP,
+		),
+		auditChain: &auditChain{},
+		webhookClient: &http.Client{
+			Timeout: config.Webhooks.Timeout.Duration,
+		},
 	}
 	s.configureRouter()
 	return s
@@ -38,10 +118,47 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (s *server) configureRouter() {
 	s.router.GET("/", s.HandleServerWork)
-	s.router.POST("/Login", s.HandleSessionsCreate)
-	s.router.POST("/Logout", s.HandleSessionsDelete)
-	s.router.POST("/Refresh", s.HandleSessionsRefresh)
-	s.router.POST("/LogoutAll", s.HandleAllSessionsDelete)
+	s.router.POST("/Login", s.audit(model.AuditLogin), s.HandleSessionsCreate)
+	s.router.POST("/LoginMFA", s.audit(model.AuditLoginMFA), s.HandleMFASessionsCreate)
+	s.router.POST("/mfa/totp/enroll", s.denyImpersonation(), s.HandleTOTPEnroll)
+	s.router.POST("/mfa/totp/confirm", s.denyImpersonation(), s.HandleTOTPConfirm)
+	s.router.POST("/password/forgot", s.HandlePasswordForgot)
+	s.router.POST("/password/reset", s.audit(model.AuditPasswordReset), s.HandlePasswordReset)
+	s.router.POST("/Register", s.HandleUsersCreate)
+	s.router.GET("/verify-email", s.HandleEmailVerify)
+	s.router.POST("/verify-email", s.HandleEmailVerify)
+	s.router.POST("/verify-email/resend", s.HandleEmailVerifyResend)
+	s.router.POST("/passwordless/start", s.HandlePasswordlessStart)
+	s.router.GET("/passwordless/verify", s.HandlePasswordlessConfirm)
+	s.router.POST("/passwordless/verify", s.audit(model.AuditLoginPasswordless), s.HandlePasswordlessVerify)
+
+	s.router.GET("/authorize", s.denyImpersonation(), s.HandleAuthorize)
+	s.router.POST("/authorize", s.denyImpersonation(), s.HandleAuthorize)
+	s.router.POST("/token", s.HandleToken)
+	s.router.GET("/userinfo", s.RequireScopes(model.ScopeOpenID), s.HandleUserInfo)
+	s.router.POST("/userinfo", s.RequireScopes(model.ScopeOpenID), s.HandleUserInfo)
+	s.router.GET("/.well-known/openid-configuration", s.HandleOpenIDConfiguration)
+	s.router.GET("/.well-known/jwks.json", s.HandleJWKS)
+
+	admin := s.router.Group("/admin", s.RequireScopes(model.ScopeAdmin))
+	admin.GET("/users", s.HandleAdminUsersList)
+	admin.GET("/users/:id", s.HandleAdminUsersGet)
+	admin.GET("/users/:id/sessions", s.HandleAdminSessionsList)
+	admin.POST("/users/:id/disable", s.audit(model.AuditUserDisable), s.HandleAdminUsersDisable)
+	admin.POST("/users/:id/enable", s.audit(model.AuditUserEnable), s.HandleAdminUsersEnable)
+	admin.POST("/users/:id/logout", s.audit(model.AuditForceLogout), s.HandleAdminSessionsDelete)
+	admin.POST("/users/:id/impersonate", s.audit(model.AuditImpersonate), s.HandleAdminImpersonate)
+	admin.GET("/audit", s.HandleAdminAuditList)
+	admin.GET("/webhooks", s.HandleAdminWebhooksList)
+	admin.POST("/webhooks", s.HandleAdminWebhooksCreate)
+	admin.POST("/webhooks/:id/test", s.HandleAdminWebhooksTest)
+	admin.GET("/webhooks/:id/deliveries", s.HandleAdminWebhookDeliveriesList)
+	admin.DELETE("/webhooks/:id", s.HandleAdminWebhooksDelete)
+
+	cookieAuth := s.router.Group("/", s.csrfProtect())
+	cookieAuth.POST("/Logout", s.audit(model.AuditLogout), s.HandleSessionsDelete)
+	cookieAuth.POST("/Refresh", s.audit(model.AuditRefresh), s.HandleSessionsRefresh)
+	cookieAuth.POST("/LogoutAll", s.audit(model.AuditLogoutAll), s.HandleAllSessionsDelete)
 }
 
 func (s *server) HandleServerWork(c *gin.Context) {
@@ -49,84 +166,144 @@ func (s *server) HandleServerWork(c *gin.Context) {
 }
 
 func (s *server) HandleSessionsRefresh(c *gin.Context) {
-	token, err := s.VerifyRefreshToken(c.Request)
+	claims, err := s.VerifyRefreshToken(c.Request)
 	if err != nil {
-		s.respond(c.Writer, c.Request, http.StatusUnauthorized, "Refresh token expired")
-		return
-	}
-	if _, ok := token.Claims.(jwt.Claims); !ok && !token.Valid {
-		s.respond(c.Writer, c.Request, http.StatusUnauthorized, err)
+		s.tokenError(c.Writer, c.Request, err)
 		return
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		refreshUUID, ok := claims["refresh_uuid"].(string)
-		if !ok {
-			s.respond(c.Writer, c.Request, http.StatusUnprocessableEntity, err)
-			return
-		}
-		userID := claims["user_id"].(string)
-		if err != nil {
-			s.respond(c.Writer, c.Request, http.StatusUnprocessableEntity, "Error occurred")
-			return
-		}
+	auditSubject(c, claims.UserID)
+	auditSession(c, claims.RefreshUUID)
 
-		deleted, delErr := s.store.Token().DeleteAuth(refreshUUID)
-		if delErr != nil || deleted == 0 { //if any goes wrong
-			s.respond(c.Writer, c.Request, http.StatusUnauthorized, "unauthorized")
-			return
+	deleted, delErr := s.store.Token().DeleteAuth(claims.RefreshUUID)
+	if delErr != nil || deleted == 0 { //if any goes wrong
+		if delErr == nil {
+			c.Set(ctxKeyAuditReason, auditRefreshReused)
 		}
+		s.respond(c.Writer, c.Request, http.StatusUnauthorized, "unauthorized")
+		return
+	}
 
-		ts, createErr := s.Create(userID)
-		if createErr != nil {
-			s.respond(c.Writer, c.Request, http.StatusForbidden, createErr.Error())
-			return
-		}
+	u, err := s.store.User().Find(claims.UserID)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnauthorized, errUnknownUser)
+		return
+	}
+	if !s.checkUserStatus(c, u) {
+		return
+	}
 
-		saveErr := s.store.Token().CreateAuth(userID, ts)
-		if saveErr != nil {
-			s.respond(c.Writer, c.Request, http.StatusForbidden, saveErr.Error())
-			return
-		}
-		tokens := map[string]string{
-			"access_token":  ts.AccessToken,
-			"refresh_token": ts.RefreshToken,
-		}
+	// The scopes granted with the session are kept, less those the user
+	// has lost since.
+	ts, createErr := s.Create(&model.TokenRequest{
+		UserID:              claims.UserID,
+		ClientID:            claims.ClientID,
+		ClientAuthenticated: claims.ClientAuthenticated,
+		Roles:               u.Roles,
+		Scopes:              model.FilterScopes(model.SplitScopes(claims.Scope), u.GrantableScopes()),
+		AuthTime:            claims.AuthTime,
+	})
+	if createErr == errSessionExpired {
+		s.error(c.Writer, c.Request, http.StatusUnauthorized, createErr)
+		return
+	}
+	if createErr != nil {
+		s.respond(c.Writer, c.Request, http.StatusForbidden, createErr.Error())
+		return
+	}
 
-		c.Writer.Header().Add("Authorization", ts.AccessToken)
+	saveErr := s.store.Token().CreateAuth(claims.UserID, ts)
+	if saveErr != nil {
+		s.respond(c.Writer, c.Request, http.StatusForbidden, saveErr.Error())
+		return
+	}
+	tokens := map[string]string{
+		"access_token":  ts.AccessToken,
+		"refresh_token": ts.RefreshToken,
+	}
 
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    ts.RefreshToken,
-			Expires:  time.Now().Add(120 * time.Minute),
-			HttpOnly: true,
-		})
+	c.Writer.Header().Add("Authorization", ts.AccessToken)
 
-		s.respond(c.Writer, c.Request, http.StatusCreated, tokens)
-	} else {
-		s.respond(c.Writer, c.Request, http.StatusUnauthorized, "refresh expired")
+	if err := s.setRefreshCookie(c.Writer, ts); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
 	}
+
+	s.respond(c.Writer, c.Request, http.StatusCreated, tokens)
 }
 
 func (s *server) HandleSessionsCreate(c *gin.Context) {
 	type request struct {
-		UserID string `json:"id"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		ClientID string `json:"client_id"`
 	}
 	req := &request{}
 	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
 		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
 		return
 	}
+	if req.Email == "" || req.Password == "" {
+		s.errorCode(c.Writer, c.Request, http.StatusBadRequest, "invalid_request", errMissingCredentials)
+		return
+	}
 
-	ts, err := s.Create(req.UserID)
+	u, err := s.store.User().FindByEmail(req.Email)
+	if err != nil {
+		u = nil
+	} else {
+		auditSubject(c, u.ID.Hex())
+	}
+	if !s.comparePassword(u, req.Password) {
+		s.errorCode(c.Writer, c.Request, http.StatusUnauthorized, "invalid_credentials", errInvalidCredentials)
+		return
+	}
+	s.rehashPassword(u, req.Password)
+
+	s.completeLogin(c, u, req.ClientID)
+}
+
+// completeLogin continues a login once the user has proven the first
+// factor: it checks the account, then asks for the second factor or issues
+// the token pair.
+func (s *server) completeLogin(c *gin.Context, u *model.User, clientID string) {
+	if !s.checkUserStatus(c, u) {
+		return
+	}
+
+	if s.config.EmailVerification.Required && !u.EmailVerified {
+		s.errorCode(c.Writer, c.Request, http.StatusForbidden, "email_not_verified", errEmailNotVerified)
+		return
+	}
+
+	if u.TOTPEnabled {
+		s.respondMFAChallenge(c, u, clientID)
+		return
+	}
+
+	s.startSession(c, u, clientID)
+}
+
+// startSession issues a token pair to a user who has just authenticated
+// and responds like /Login.
+func (s *server) startSession(c *gin.Context, u *model.User, clientID string) {
+	userID := u.ID.Hex()
+	ts, err := s.Create(&model.TokenRequest{
+		UserID:   userID,
+		ClientID: clientID,
+		Roles:    u.Roles,
+		Scopes:   u.Scopes,
+	})
 	if err != nil {
 		s.respond(c.Writer, c.Request, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 
-	err = s.store.Token().CreateAuth(req.UserID, ts)
+	auditSession(c, ts.RefreshUuid)
+
+	err = s.store.Token().CreateAuth(userID, ts)
 	if err != nil {
 		s.respond(c.Writer, c.Request, http.StatusUnprocessableEntity, err.Error())
+		return
 	}
 
 	tokens := map[string]string{
@@ -136,12 +313,10 @@ func (s *server) HandleSessionsCreate(c *gin.Context) {
 
 	c.Writer.Header().Set("Authorization", ts.AccessToken)
 
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    ts.RefreshToken,
-		Expires:  time.Now().Add(120 * time.Minute),
-		HttpOnly: true,
-	})
+	if err := s.setRefreshCookie(c.Writer, ts); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
 
 	s.respond(c.Writer, c.Request, http.StatusOK, tokens)
 }
@@ -149,30 +324,37 @@ func (s *server) HandleSessionsCreate(c *gin.Context) {
 func (s *server) HandleSessionsDelete(c *gin.Context) {
 	metadata, err := s.ExtractTokenMetadata(c.Request)
 	if err != nil {
-		s.respond(c.Writer, c.Request, http.StatusUnauthorized, "unauthorized")
+		s.tokenError(c.Writer, c.Request, err)
 		return
 	}
 
+	auditSubject(c, metadata.UserID)
+	auditSession(c, metadata.RefreshUUID)
+
 	_, delErr := s.store.Token().DeleteAuth(metadata.RefreshUUID)
 	if delErr != nil {
 		s.respond(c.Writer, c.Request, http.StatusUnauthorized, delErr.Error())
 		return
 	}
+	s.clearRefreshCookie(c.Writer)
 	s.respond(c.Writer, c.Request, http.StatusOK, "Successfully logged out")
 }
 
 func (s *server) HandleAllSessionsDelete(c *gin.Context) {
 	metadata, err := s.ExtractTokenMetadata(c.Request)
 	if err != nil {
-		s.respond(c.Writer, c.Request, http.StatusUnauthorized, "unauthorized")
+		s.tokenError(c.Writer, c.Request, err)
 		return
 	}
 
+	auditSubject(c, metadata.UserID)
+
 	delErr := s.store.Token().DeleteTokens(metadata)
 	if delErr != nil {
 		s.respond(c.Writer, c.Request, http.StatusUnauthorized, delErr.Error())
 		return
 	}
+	s.clearRefreshCookie(c.Writer)
 	s.respond(c.Writer, c.Request, http.StatusOK, "Successfully logged out")
 }
 
@@ -186,124 +368,129 @@ func (s *server) ExtractAccessToken(r *http.Request) string {
 }
 
 func (s *server) ExtractRefreshToken(r *http.Request) string {
-	refreshToken, err := r.Cookie("refresh_token")
+	if token := r.Header.Get(refreshTokenHeader); token != "" {
+		return token
+	}
+	refreshToken, err := r.Cookie(s.config.Cookie.cookieName())
 	if err != nil {
 		return ""
 	}
 	return refreshToken.Value
 }
 
-func (s *server) VerifyRefreshToken(r *http.Request) (*jwt.Token, error) {
+func (s *server) VerifyRefreshToken(r *http.Request) (*model.RefreshClaims, error) {
 	tokenString := s.ExtractRefreshToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("REFRESH_SECRET")), nil
-	})
-	if err != nil {
+	claims := &model.RefreshClaims{}
+	if err := s.parseToken(tokenString, refreshTokenType, []byte(os.Getenv("REFRESH_SECRET")), claims); err != nil {
 		return nil, err
 	}
 
-	return token, nil
+	return claims, nil
 }
 
-func (s *server) VerifyToken(r *http.Request) (*jwt.Token, error) {
+func (s *server) VerifyToken(r *http.Request) (*model.AccessClaims, error) {
 	tokenString := s.ExtractAccessToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("ACCESS_SECRET")), nil
-	})
-	if err != nil {
+	claims := &model.AccessClaims{}
+	if err := s.parseToken(tokenString, accessTokenType, []byte(os.Getenv("ACCESS_SECRET")), claims); err != nil {
 		return nil, err
 	}
 
-	return token, nil
+	return claims, nil
 }
 
 func (s *server) TokenValid(r *http.Request) error {
-	token, err := s.VerifyToken(r)
-	if err != nil {
-		return err
-	}
-	if _, ok := token.Claims.(jwt.Claims); !ok || !token.Valid {
-		return err
-	}
-	return nil
+	_, err := s.VerifyToken(r)
+	return err
 }
 
 func (s *server) ExtractTokenMetadata(r *http.Request) (*model.AccessDetails, error) {
-
-	accessToken, err := s.VerifyToken(r)
+	accessClaims, err := s.VerifyToken(r)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.VerifyRefreshToken(r)
+	refreshClaims, err := s.VerifyRefreshToken(r)
 	if err != nil {
 		return nil, err
 	}
 
-	claims, ok := accessToken.Claims.(jwt.MapClaims)
-	if ok && accessToken.Valid {
-		accessUUID, ok := claims["access_uuid"].(string)
-		if !ok {
-			return nil, err
-		}
-		userID := claims["user_id"].(string)
-		if err != nil {
-			return nil, err
-		}
-
-		fmt.Println(accessUUID, userID)
-
-		claims, ok := refreshToken.Claims.(jwt.MapClaims)
-		if ok && refreshToken.Valid {
-			refreshUUID, ok := claims["refresh_uuid"].(string)
-			if !ok {
-				return nil, err
-			}
-			fmt.Println(refreshUUID)
+	return &model.AccessDetails{
+		AccessUUID:  accessClaims.AccessUUID,
+		UserID:      accessClaims.UserID,
+		ClientID:    accessClaims.ClientID,
+		RefreshUUID: refreshClaims.RefreshUUID,
+		Roles:       accessClaims.Roles,
+		Scopes:      model.SplitScopes(accessClaims.Scope),
+		ActorID:     accessClaims.ActorID(),
+	}, nil
+}
 
-			return &model.AccessDetails{
-				AccessUUID:  accessUUID,
-				UserID:      userID,
-				RefreshUUID: refreshUUID,
-			}, nil
+func (s *server) Create(tr *model.TokenRequest) (*model.TokenDetails, error) {
+	now := time.Now()
+	if tr.AuthTime == 0 {
+		tr.AuthTime = now.Unix()
+	}
 
+	accessTTL, refreshTTL := s.config.Tokens.lifetimes(tr)
+	if tr.AccessTTL > 0 {
+		accessTTL = tr.AccessTTL
+	}
+	atExpires, rtExpires := now.Add(accessTTL), now.Add(refreshTTL)
+	if sessionEnd := s.config.Tokens.sessionEnd(tr.AuthTime); !sessionEnd.IsZero() {
+		if !now.Before(sessionEnd) {
+			return nil, errSessionExpired
+		}
+		if atExpires.After(sessionEnd) {
+			atExpires = sessionEnd
+		}
+		if rtExpires.After(sessionEnd) {
+			rtExpires = sessionEnd
 		}
-		return nil, err
 	}
-	return nil, err
-}
 
-func (s *server) Create(userid string) (*model.TokenDetails, error) {
 	td := &model.TokenDetails{}
-	td.AtExpires = time.Now().Add(time.Minute * 15).Unix()
+	td.AtExpires = atExpires.Unix()
 	td.AccessUuid = uuid.NewV4().String()
-	td.RtExpires = time.Now().Add(time.Hour * 24 * 7).Unix()
-	td.RefreshUuid = uuid.NewV4().String()
 
 	var err error
 	_ = os.Setenv("ACCESS_SECRET", "jdnfksdmfksd") //this should be in an env file
-	atClaims := jwt.MapClaims{}
-	atClaims["authorized"] = true
-	atClaims["access_uuid"] = td.AccessUuid
-	atClaims["user_id"] = userid
-	atClaims["exp"] = td.AtExpires
+	atClaims := &model.AccessClaims{
+		RegisteredClaims: s.registeredClaims(tr.Subject(), td.AccessUuid, now, td.AtExpires),
+		Authorized:       true,
+		AccessUUID:       td.AccessUuid,
+		UserID:           tr.UserID,
+		ClientID:         tr.ClientID,
+		Roles:            tr.Roles,
+		Scope:            model.JoinScopes(tr.Scopes),
+		AuthTime:         tr.AuthTime,
+	}
+	if tr.ActorID != "" {
+		atClaims.Act = &model.Actor{Subject: tr.ActorID}
+	}
 	at := jwt.NewWithClaims(jwt.SigningMethodHS512, atClaims)
+	at.Header["typ"] = accessTokenType
 	td.AccessToken, err = at.SignedString([]byte(os.Getenv("ACCESS_SECRET")))
 	if err != nil {
 		return nil, err
 	}
+	if tr.AccessOnly {
+		return td, nil
+	}
+
+	td.RtExpires = rtExpires.Unix()
+	td.RefreshUuid = uuid.NewV4().String()
 	_ = os.Setenv("REFRESH_SECRET", "mcmvmkmsdnfsdmfdsjf") //this should be in an env file
-	rtClaims := jwt.MapClaims{}
-	rtClaims["refresh_uuid"] = td.RefreshUuid
-	rtClaims["user_id"] = userid
-	rtClaims["exp"] = td.RtExpires
+	rtClaims := &model.RefreshClaims{
+		RegisteredClaims:    s.registeredClaims(tr.UserID, td.RefreshUuid, now, td.RtExpires),
+		RefreshUUID:         td.RefreshUuid,
+		UserID:              tr.UserID,
+		ClientID:            tr.ClientID,
+		AuthTime:            tr.AuthTime,
+		Scope:               model.JoinScopes(tr.Scopes),
+		ClientAuthenticated: tr.ClientAuthenticated,
+	}
 	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
+	rt.Header["typ"] = refreshTokenType
 	td.RefreshToken, err = rt.SignedString([]byte(os.Getenv("REFRESH_SECRET")))
 	if err != nil {
 		return nil, err
@@ -311,10 +498,24 @@ func (s *server) Create(userid string) (*model.TokenDetails, error) {
 	return td, nil
 }
 
+// randomToken returns 32 random bytes encoded for use in URLs and cookies.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func (s *server) error(w http.ResponseWriter, r *http.Request, code int, err error) {
 	s.respond(w, r, code, map[string]string{"error": err.Error()})
 }
 
+func (s *server) errorCode(w http.ResponseWriter, r *http.Request, status int, code string, err error) {
+	s.respond(w, r, status, map[string]string{"error": err.Error(), "code": code})
+}
+
 func (s *server) respond(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
 	w.WriteHeader(code)
 	if data != nil {
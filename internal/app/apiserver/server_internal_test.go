@@ -3,19 +3,22 @@ package apiserver
 import (
 	"bytes"
 	"encoding/json"
-	"github.com/gorilla/mux"
-	"github.com/psihachina/go-test-work.git/internal/app/store"
-	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
-	"github.com/sirupsen/logrus"
-	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"reflect"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestServer_HandleUsersCreate(t *testing.T) {
-	s := newServer(teststore.New())
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
 	testCases := []struct {
 		name         string
 		payload      interface{}
@@ -27,7 +30,7 @@ func TestServer_HandleUsersCreate(t *testing.T) {
 				"email":    "user@example.com",
 				"password": "password",
 			},
-			expectedCode: http.StatusCreated,
+			expectedCode: http.StatusAccepted,
 		},
 		{
 			name:         "invalid payload",
@@ -41,6 +44,14 @@ func TestServer_HandleUsersCreate(t *testing.T) {
 			},
 			expectedCode: http.StatusUnprocessableEntity,
 		},
+		{
+			name: "invalid email",
+			payload: map[string]string{
+				"email":    "invalid",
+				"password": "password",
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -56,9 +67,10 @@ func TestServer_HandleUsersCreate(t *testing.T) {
 }
 
 func TestServer_HandleSessionCreate(t *testing.T) {
-	var id string
 	store := teststore.New()
-	s := newServer(store)
+	u := model.TestUser(t)
+	_ = store.User().Create(u)
+	s := newServer(store, mailer.NewLog(ioutil.Discard), NewConfig())
 	testCases := []struct {
 		name         string
 		payload      interface{}
@@ -67,15 +79,11 @@ func TestServer_HandleSessionCreate(t *testing.T) {
 		{
 			name: "valid",
 			payload: map[string]string{
-				"id": "123123",
+				"email":    u.Email,
+				"password": "password",
 			},
 			expectedCode: http.StatusOK,
 		},
-		{
-			name:         "invalid payload",
-			payload:      "invalid",
-			expectedCode: http.StatusBadRequest,
-		},
 		{
 			name: "invalid email",
 			payload: map[string]string{
@@ -92,6 +100,26 @@ func TestServer_HandleSessionCreate(t *testing.T) {
 			},
 			expectedCode: http.StatusUnauthorized,
 		},
+		{
+			name: "unknown user",
+			payload: map[string]string{
+				"email":    "unknown@example.org",
+				"password": "password",
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "id without credentials",
+			payload: map[string]string{
+				"id": u.ID.Hex(),
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid payload",
+			payload:      "invalid",
+			expectedCode: http.StatusBadRequest,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -103,39 +131,528 @@ func TestServer_HandleSessionCreate(t *testing.T) {
 			assert.Equal(t, tc.expectedCode, rec.Code)
 		})
 	}
-
 }
 
-func Test_server_HandleSessionsRefresh(t *testing.T) {
-	type fields struct {
-		router *mux.Router
-		logger *logrus.Logger
-		store  store.Store
+func TestServer_RefreshCookie(t *testing.T) {
+	config := NewConfig()
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), config)
+
+	tokens, cookies := testLogin(t, s)
+
+	refreshCookies := cookiesNamed(cookies, "refresh_token")
+	assert.Len(t, refreshCookies, len(config.Cookie.Paths))
+	for i, cookie := range refreshCookies {
+		assert.Equal(t, config.Cookie.Paths[i], cookie.Path)
+		assert.True(t, cookie.Secure)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+		assert.Greater(t, cookie.MaxAge, 6*24*60*60)
 	}
-	tests := []struct {
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/Logout", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"])
+	testAddCookies(req, cookies)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	cleared := rec.Result().Cookies()
+	assert.Len(t, cleared, len(config.Cookie.Paths)+1)
+	for _, cookie := range cleared {
+		assert.Equal(t, "", cookie.Value)
+		assert.Equal(t, -1, cookie.MaxAge)
+	}
+}
+
+func TestServer_CSRF(t *testing.T) {
+	config := NewConfig()
+	config.CSRF.TrustedOrigins = []string{"https://app.example.org"}
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), config)
+
+	testCases := []struct {
 		name         string
-		payload      interface{}
-		expectedCode http.HandlerFunc
+		prepare      func(*http.Request, map[string]string, []*http.Cookie)
+		expectedCode int
 	}{
 		{
 			name: "valid",
-			payload: map[string]string{
-				"email":    u.Email,
-				"password": u.Password,
+			prepare: func(req *http.Request, _ map[string]string, cookies []*http.Cookie) {
+				testAddCookies(req, cookies)
+				req.Header.Set("Origin", "https://app.example.org")
 			},
-			expectedCode: http.StatusOK,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name: "missing csrf header",
+			prepare: func(req *http.Request, _ map[string]string, cookies []*http.Cookie) {
+				for _, cookie := range cookies {
+					req.AddCookie(cookie)
+				}
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "untrusted origin",
+			prepare: func(req *http.Request, _ map[string]string, cookies []*http.Cookie) {
+				testAddCookies(req, cookies)
+				req.Header.Set("Origin", "https://evil.example.com")
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "same origin",
+			prepare: func(req *http.Request, _ map[string]string, cookies []*http.Cookie) {
+				testAddCookies(req, cookies)
+				req.Host = "auth.example.org"
+				req.Header.Set("X-Forwarded-Proto", "https")
+				req.Header.Set("Origin", "https://auth.example.org")
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name: "same host over http",
+			prepare: func(req *http.Request, _ map[string]string, cookies []*http.Cookie) {
+				testAddCookies(req, cookies)
+				req.Host = "auth.example.org"
+				req.Header.Set("X-Forwarded-Proto", "https")
+				req.Header.Set("Origin", "http://auth.example.org")
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "untrusted referer",
+			prepare: func(req *http.Request, _ map[string]string, cookies []*http.Cookie) {
+				testAddCookies(req, cookies)
+				req.Header.Set("Referer", "https://evil.example.com/page")
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "explicit refresh token",
+			prepare: func(req *http.Request, tokens map[string]string, _ []*http.Cookie) {
+				req.Header.Set(refreshTokenHeader, tokens["refresh_token"])
+			},
+			expectedCode: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens, cookies := testLogin(t, s)
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+			tc.prepare(req, tokens, cookies)
+			s.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestCookieConfig_HostPrefix(t *testing.T) {
+	c := &CookieConfig{
+		Name:       "refresh_token",
+		Domain:     "example.org",
+		Paths:      []string{"/Refresh"},
+		HostPrefix: true,
+	}
+
+	cookies := c.cookies("value", time.Now().Add(time.Hour), 3600)
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "__Host-refresh_token", cookies[0].Name)
+	assert.Equal(t, "/", cookies[0].Path)
+	assert.Equal(t, "", cookies[0].Domain)
+	assert.True(t, cookies[0].Secure)
+}
+
+func TestServer_HandleSessionsRefresh(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	tokens, _ := testLogin(t, s)
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"refresh_uuid": "id",
+		"iss":          "http://localhost:8080",
+		"aud":          "go-test-work",
+		"iat":          now.Unix(),
+		"exp":          now.Add(time.Minute).Unix(),
+	}
+
+	testCases := []struct {
+		name         string
+		token        string
+		expectedCode int
+		errorCode    string
+	}{
+		{
+			name:         "valid",
+			token:        tokens["refresh_token"],
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "missing user_id",
+			token:        testSign(t, claims, refreshTokenType, "REFRESH_SECRET"),
+			expectedCode: http.StatusBadRequest,
+			errorCode:    "token_malformed",
+		},
+		{
+			name:         "access token",
+			token:        testSign(t, claims, accessTokenType, "REFRESH_SECRET"),
+			expectedCode: http.StatusUnauthorized,
+			errorCode:    "token_wrong_type",
+		},
+		{
+			name:         "bad signature",
+			token:        testSign(t, claims, refreshTokenType, "ACCESS_SECRET"),
+			expectedCode: http.StatusUnauthorized,
+			errorCode:    "token_signature_invalid",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+			req.Header.Set(refreshTokenHeader, tc.token)
+			s.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+
+			body := map[string]string{}
+			_ = json.NewDecoder(rec.Body).Decode(&body)
+			assert.Equal(t, tc.errorCode, body["code"])
+		})
+	}
+}
+
+func TestServer_Create(t *testing.T) {
+	config := NewConfig()
+	config.Tokens.Clients = map[string]TokenTTL{
+		"mobile": {RefreshTTL: Duration{30 * 24 * time.Hour}},
+		"kiosk":  {AccessTTL: Duration{time.Hour}},
+	}
+	config.Tokens.Roles = map[string]TokenTTL{
+		"admin":   {AccessTTL: Duration{5 * time.Minute}},
+		"support": {AccessTTL: Duration{10 * time.Minute}},
+	}
+	config.Tokens.MaxSessionLifetime = Duration{14 * 24 * time.Hour}
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), config)
+
+	testCases := []struct {
+		name       string
+		tr         *model.TokenRequest
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+		err        error
+	}{
+		{
+			name:       "defaults",
+			tr:         &model.TokenRequest{UserID: "1"},
+			accessTTL:  15 * time.Minute,
+			refreshTTL: 7 * 24 * time.Hour,
+		},
+		{
+			name:       "client override capped by session lifetime",
+			tr:         &model.TokenRequest{UserID: "1", ClientID: "mobile", ClientAuthenticated: true},
+			accessTTL:  15 * time.Minute,
+			refreshTTL: 14 * 24 * time.Hour,
+		},
+		{
+			name:       "client override",
+			tr:         &model.TokenRequest{UserID: "1", ClientID: "kiosk", ClientAuthenticated: true},
+			accessTTL:  time.Hour,
+			refreshTTL: 7 * 24 * time.Hour,
+		},
+		{
+			name:       "unauthenticated client",
+			tr:         &model.TokenRequest{UserID: "1", ClientID: "kiosk"},
+			accessTTL:  15 * time.Minute,
+			refreshTTL: 7 * 24 * time.Hour,
+		},
+		{
+			name:       "role override shortens client override",
+			tr:         &model.TokenRequest{UserID: "1", ClientID: "kiosk", ClientAuthenticated: true, Roles: []string{"admin"}},
+			accessTTL:  5 * time.Minute,
+			refreshTTL: 7 * 24 * time.Hour,
+		},
+		{
+			name:       "shortest role override",
+			tr:         &model.TokenRequest{UserID: "1", Roles: []string{"support", "admin"}},
+			accessTTL:  5 * time.Minute,
+			refreshTTL: 7 * 24 * time.Hour,
+		},
+		{
+			name:       "session close to its end",
+			tr:         &model.TokenRequest{UserID: "1", AuthTime: time.Now().Add(-14*24*time.Hour + time.Hour).Unix()},
+			accessTTL:  15 * time.Minute,
+			refreshTTL: time.Hour,
+		},
+		{
+			name: "session expired",
+			tr:   &model.TokenRequest{UserID: "1", AuthTime: time.Now().Add(-15 * 24 * time.Hour).Unix()},
+			err:  errSessionExpired,
 		},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			s := &server{
-				router: tt.fields.router,
-				logger: tt.fields.logger,
-				store:  tt.fields.store,
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			td, err := s.Create(tc.tr)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
 			}
-			if got := s.HandleSessionsRefresh(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("HandleSessionsRefresh() = %v, want %v", got, tt.want)
+			assert.NoError(t, err)
+			assert.InDelta(t, now.Add(tc.accessTTL).Unix(), td.AtExpires, 1)
+			assert.InDelta(t, now.Add(tc.refreshTTL).Unix(), td.RtExpires, 1)
+		})
+	}
+}
+
+func TestServer_VerifyToken(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	td, err := s.Create(&model.TokenRequest{UserID: "1"})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"access_uuid": "id",
+			"user_id":     "1",
+			"iss":         "http://localhost:8080",
+			"aud":         []string{"go-test-work"},
+			"sub":         "1",
+			"jti":         "id",
+			"iat":         now.Unix(),
+			"nbf":         now.Unix(),
+			"exp":         now.Add(time.Minute).Unix(),
+		}
+	}
+
+	testCases := []struct {
+		name  string
+		token func() string
+		err   error
+	}{
+		{
+			name:  "issued",
+			token: func() string { return td.AccessToken },
+		},
+		{
+			name:  "valid",
+			token: func() string { return testSign(t, validClaims(), accessTokenType, "ACCESS_SECRET") },
+		},
+		{
+			name: "expired within leeway",
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = now.Add(-10 * time.Second).Unix()
+				return testSign(t, claims, accessTokenType, "ACCESS_SECRET")
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = now.Add(-time.Minute).Unix()
+				return testSign(t, claims, accessTokenType, "ACCESS_SECRET")
+			},
+			err: model.ErrTokenExpired,
+		},
+		{
+			name: "not valid yet",
+			token: func() string {
+				claims := validClaims()
+				claims["nbf"] = now.Add(time.Minute).Unix()
+				return testSign(t, claims, accessTokenType, "ACCESS_SECRET")
+			},
+			err: model.ErrTokenNotValidYet,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := validClaims()
+				claims["iss"] = "someone-else"
+				return testSign(t, claims, accessTokenType, "ACCESS_SECRET")
+			},
+			err: model.ErrTokenInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = "someone-else"
+				return testSign(t, claims, accessTokenType, "ACCESS_SECRET")
+			},
+			err: model.ErrTokenInvalidAudience,
+		},
+		{
+			name: "missing user_id",
+			token: func() string {
+				claims := validClaims()
+				delete(claims, "user_id")
+				return testSign(t, claims, accessTokenType, "ACCESS_SECRET")
+			},
+			err: model.ErrTokenMalformed,
+		},
+		{
+			name: "user_id of the wrong type",
+			token: func() string {
+				claims := validClaims()
+				claims["user_id"] = 1
+				return testSign(t, claims, accessTokenType, "ACCESS_SECRET")
+			},
+			err: model.ErrTokenMalformed,
+		},
+		{
+			name:  "garbage",
+			token: func() string { return "garbage" },
+			err:   model.ErrTokenMalformed,
+		},
+		{
+			name:  "bad signature",
+			token: func() string { return testSign(t, validClaims(), accessTokenType, "REFRESH_SECRET") },
+			err:   model.ErrTokenSignatureInvalid,
+		},
+		{
+			name:  "refresh token used as access token",
+			token: func() string { return testSign(t, validClaims(), refreshTokenType, "ACCESS_SECRET") },
+			err:   model.ErrTokenWrongType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token())
+			_, err := s.VerifyToken(req)
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestServer_RequireScopesAndRole(t *testing.T) {
+	s := newServer(teststore.New(), mailer.NewLog(ioutil.Discard), NewConfig())
+	s.router.GET("/orders", s.RequireScopes("orders:read"), s.HandleServerWork)
+	s.router.POST("/orders", s.RequireScopes("orders:read", "orders:write"), s.HandleServerWork)
+	s.router.GET("/admin", s.authenticate(), s.RequireRole("admin"), s.HandleServerWork)
+
+	u := model.TestUser(t)
+	u.Roles = []string{"support"}
+	u.Scopes = []string{"orders:read"}
+	tokens, _ := testLoginUser(t, s, u)
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		expectedCode int
+		errorCode    string
+	}{
+		{
+			name:         "granted scope",
+			method:       http.MethodGet,
+			path:         "/orders",
+			token:        tokens["access_token"],
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "missing scope",
+			method:       http.MethodPost,
+			path:         "/orders",
+			token:        tokens["access_token"],
+			expectedCode: http.StatusForbidden,
+			errorCode:    "insufficient_scope",
+		},
+		{
+			name:         "missing role",
+			method:       http.MethodGet,
+			path:         "/admin",
+			token:        tokens["access_token"],
+			expectedCode: http.StatusForbidden,
+			errorCode:    "insufficient_role",
+		},
+		{
+			name:         "no token",
+			method:       http.MethodGet,
+			path:         "/orders",
+			expectedCode: http.StatusUnauthorized,
+			errorCode:    "token_missing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			s.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.errorCode != "" {
+				body := map[string]interface{}{}
+				_ = json.NewDecoder(rec.Body).Decode(&body)
+				assert.Equal(t, tc.errorCode, body["code"])
 			}
 		})
 	}
 }
+
+func testLogin(t *testing.T, s *server) (map[string]string, []*http.Cookie) {
+	t.Helper()
+
+	return testLoginUser(t, s, model.TestUser(t))
+}
+
+func testLoginUser(t *testing.T, s *server, u *model.User) (map[string]string, []*http.Cookie) {
+	t.Helper()
+
+	if err := u.EncryptPassword(s.passwordHasher); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.User().Create(u); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := testJSONRequest(s, "/Login", "", map[string]string{"email": u.Email, "password": u.Password})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	tokens := map[string]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&tokens)
+
+	return tokens, rec.Result().Cookies()
+}
+
+// testAddCookies attaches the login cookies to req and echoes the CSRF
+// cookie in the CSRF header, as a browser client would.
+func testAddCookies(req *http.Request, cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+		if cookie.Name == "csrf_token" {
+			req.Header.Set("X-CSRF-Token", cookie.Value)
+		}
+	}
+}
+
+func cookiesNamed(cookies []*http.Cookie, name string) []*http.Cookie {
+	var res []*http.Cookie
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			res = append(res, cookie)
+		}
+	}
+	return res
+}
+
+func testSign(t *testing.T, claims jwt.MapClaims, typ string, secretEnv string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["typ"] = typ
+	signed, err := token.SignedString([]byte(os.Getenv(secretEnv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
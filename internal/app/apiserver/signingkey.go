@@ -0,0 +1,107 @@
+package apiserver
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// signingKey is the RSA key of the service, loaded or generated on first
+// use.
+type signingKey struct {
+	path string
+	once sync.Once
+	key  *rsa.PrivateKey
+	kid  string
+	err  error
+}
+
+func (k *signingKey) load() (*rsa.PrivateKey, string, error) {
+	k.once.Do(func() {
+		if k.path == "" {
+			k.key, k.err = rsa.GenerateKey(rand.Reader, 2048)
+		} else {
+			var pem []byte
+			if pem, k.err = ioutil.ReadFile(k.path); k.err == nil {
+				k.key, k.err = jwt.ParseRSAPrivateKeyFromPEM(pem)
+			}
+		}
+		if k.err != nil {
+			return
+		}
+
+		sum := sha256.Sum256(k.key.PublicKey.N.Bytes())
+		k.kid = base64.RawURLEncoding.EncodeToString(sum[:8])
+	})
+
+	return k.key, k.kid, k.err
+}
+
+// jwk returns the public key as a JSON Web Key.
+func (k *signingKey) jwk() (map[string]string, error) {
+	key, kid, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": jwt.SigningMethodRS256.Alg(),
+		"kid": kid,
+		"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+	}, nil
+}
+
+// sign signs claims with RS256.
+func (k *signingKey) sign(claims jwt.Claims, typ string) (string, error) {
+	key, kid, err := k.load()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	token.Header["typ"] = typ
+
+	return token.SignedString(key)
+}
+
+// verify checks that tokenString was signed with the key and has the typ
+// header, and decodes it into claims. The errors returned are those of the
+// model package.
+func (k *signingKey) verify(tokenString string, typ string, claims jwt.Claims) error {
+	key, _, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return &key.PublicKey, nil
+	})
+	if err != nil {
+		if vErr, ok := err.(*jwt.ValidationError); ok && vErr.Errors&jwt.ValidationErrorMalformed != 0 {
+			return model.ErrTokenMalformed
+		}
+		return model.ErrTokenSignatureInvalid
+	}
+
+	if t, _ := token.Header["typ"].(string); t != typ {
+		return model.ErrTokenWrongType
+	}
+
+	return claims.Valid()
+}
@@ -0,0 +1,75 @@
+package apiserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_SQLStore(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, "")
+	defer teardown()
+
+	s := newServer(sqlstore.New(db), &testMailer{}, NewConfig())
+	u := model.TestUser(t)
+	tokens, _ := testLoginUser(t, s, u)
+
+	refresh := func() int {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+		req.Header.Set(refreshTokenHeader, tokens["refresh_token"])
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusCreated, refresh())
+	assert.Equal(t, http.StatusUnauthorized, refresh())
+
+	events, err := s.store.Audit().Find(&model.AuditFilter{UserID: u.ID.Hex()})
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	report, err := verifyAuditLog(s.store.Audit(), s.signingKey)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Events)
+}
+
+func TestNewStore_TokenStoreDriver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apiserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		driver  string
+		isValid bool
+	}{
+		{driver: "database", isValid: true},
+		{driver: "mongodb", isValid: true},
+		{driver: "", isValid: true},
+		{driver: "memcached", isValid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.driver, func(t *testing.T) {
+			config := NewConfig()
+			config.DatabaseURL = "file://" + filepath.Join(dir, "store.db")
+			config.TokenStore.Driver = tc.driver
+
+			_, closeStore, err := newStore(config)
+			if tc.isValid {
+				assert.NoError(t, err)
+				closeStore()
+			} else {
+				assert.EqualError(t, err, "unknown token store driver: memcached")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package apiserver
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// checkUserStatus refuses tokens to a user whose account is not active,
+// with an error code telling why. It reports whether the user is active.
+func (s *server) checkUserStatus(c *gin.Context, u *model.User) bool {
+	code, err := userStatusError(u)
+	if err == nil {
+		return true
+	}
+	s.errorCode(c.Writer, c.Request, http.StatusForbidden, code, err)
+
+	return false
+}
+
+// userStatusError returns the error code and error that tell why the user
+// may not be issued tokens, or a nil error if the account is active.
+func userStatusError(u *model.User) (string, error) {
+	if u.Active() {
+		return "", nil
+	}
+
+	switch u.Status {
+	case model.UserStatusDisabled:
+		return "account_disabled", errAccountDisabled
+	case model.UserStatusLocked:
+		return "account_locked", errAccountLocked
+	case model.UserStatusPendingVerification:
+		return "account_pending_verification", errAccountPending
+	}
+
+	return "account_inactive", errAccountStatus
+}
@@ -0,0 +1,119 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_UserStatus_Login(t *testing.T) {
+	testCases := []struct {
+		status       string
+		expectedCode int
+		errorCode    string
+	}{
+		{status: "", expectedCode: http.StatusOK},
+		{status: model.UserStatusActive, expectedCode: http.StatusOK},
+		{status: model.UserStatusDisabled, expectedCode: http.StatusForbidden, errorCode: "account_disabled"},
+		{status: model.UserStatusLocked, expectedCode: http.StatusForbidden, errorCode: "account_locked"},
+		{status: model.UserStatusPendingVerification, expectedCode: http.StatusForbidden, errorCode: "account_pending_verification"},
+		{status: "archived", expectedCode: http.StatusForbidden, errorCode: "account_inactive"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.status, func(t *testing.T) {
+			s := newServer(teststore.New(), &testMailer{}, NewConfig())
+			u := model.TestUser(t)
+			assert.NoError(t, s.store.User().Create(u))
+			u.Status = tc.status
+
+			rec := testJSONRequest(s, "/Login", "", map[string]string{"email": u.Email, "password": "password"})
+			assert.Equal(t, tc.expectedCode, rec.Code)
+
+			body := map[string]string{}
+			_ = json.NewDecoder(rec.Body).Decode(&body)
+			assert.Equal(t, tc.errorCode, body["code"])
+		})
+	}
+}
+
+func TestServer_UserStatus_Refresh(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+	u := model.TestUser(t)
+	tokens, _ := testLoginUser(t, s, u)
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+		req.Header.Set(refreshTokenHeader, refreshToken)
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	u.Status = model.UserStatusLocked
+	rec := refresh(tokens["refresh_token"])
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "account_locked")
+}
+
+func TestServer_UserStatus_DisableRevokesSessions(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+	admin := model.TestUser(t)
+	admin.Email = "admin@example.org"
+	admin.Scopes = []string{model.ScopeAdmin}
+	adminTokens, _ := testLoginUser(t, s, admin)
+	u := &model.User{Email: "user@example.com", EmailVerified: true, Password: "password"}
+	tokens, _ := testLoginUser(t, s, u)
+
+	rec := testJSONRequest(s, "/admin/users/"+u.ID.Hex()+"/disable", adminTokens["access_token"], nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	sessions, err := s.store.Token().FindByUser(u.ID.Hex())
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// Enabling the account again does not bring the sessions back.
+	testJSONRequest(s, "/admin/users/"+u.ID.Hex()+"/enable", adminTokens["access_token"], nil)
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/Refresh", nil)
+	req.Header.Set(refreshTokenHeader, tokens["refresh_token"])
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_UserStatus_OAuth(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+	tokens := testOAuthSetup(t, s)
+	u, _ := s.store.User().FindByEmail("user@example.org")
+	code := testAuthorize(t, s, tokens["access_token"])
+
+	u.Status = model.UserStatusLocked
+
+	rec := testTokenRequest(s, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {"spa"},
+		"redirect_uri":  {"https://app.example.org/callback"},
+		"code_verifier": {testCodeVerifier},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	res := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	assert.Equal(t, "invalid_grant", res["error"])
+	assert.Nil(t, res["refresh_token"])
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/authorize?"+testAuthorizeQuery(nil).Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"])
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	assert.Equal(t, "access_denied", location.Query().Get("error"))
+	assert.Empty(t, location.Query().Get("code"))
+}
@@ -0,0 +1,206 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// HandleUsersCreate registers a user and emails a verification link. Like
+// /password/forgot it answers the same when the email is already
+// registered; the owner of the address is told about the attempt instead.
+// Until the email is verified, /Login answers email_not_verified when
+// verification is required.
+func (s *server) HandleUsersCreate(c *gin.Context) {
+	type request struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	// The password is hashed for an existing email too, so that the time
+	// taken does not tell the two apart.
+	u := &model.User{
+		Email:    req.Email,
+		Password: req.Password,
+	}
+	if err := u.ValidatePassword(s.passwordPolicy); err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := u.EncryptPassword(s.passwordHasher); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+	if err := u.Validate(); err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnprocessableEntity, err)
+		return
+	}
+	u.Sanitize()
+
+	existing, err := s.store.User().FindByEmail(req.Email)
+	switch err {
+	case nil:
+		if err := s.sendRegistrationNotice(existing); err != nil {
+			s.logger.Errorf("registration notice for %s: %v", existing.ID.Hex(), err)
+		}
+	case store.ErrRecordNotFound:
+		if err := s.store.User().Create(u); err != nil {
+			s.error(c.Writer, c.Request, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if err := s.sendEmailVerification(u); err != nil {
+			s.logger.Errorf("email verification for %s: %v", u.ID.Hex(), err)
+		}
+	default:
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusAccepted, "A verification link has been sent to the email")
+}
+
+// HandleEmailVerify marks the email of a user as verified. The token comes
+// from the query of the mailed link or from a JSON body.
+func (s *server) HandleEmailVerify(c *gin.Context) {
+	type request struct {
+		Token string `json:"token"`
+	}
+	req := &request{Token: c.Query("token")}
+	if req.Token == "" {
+		if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+			s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	t, err := s.store.OneTimeToken().Consume(model.HashToken(req.Token), model.PurposeEmailVerification)
+	if err == store.ErrRecordNotFound {
+		s.errorCode(c.Writer, c.Request, http.StatusBadRequest, "verification_token_invalid", errInvalidVerifyToken)
+		return
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+	if t.Expired() {
+		s.errorCode(c.Writer, c.Request, http.StatusBadRequest, "verification_token_expired", errVerifyTokenExpired)
+		return
+	}
+
+	u, err := s.store.User().Find(t.UserID)
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, errUnknownUser)
+		return
+	}
+
+	u.EmailVerified = true
+	if u.Status == model.UserStatusPendingVerification {
+		u.Status = model.UserStatusActive
+	}
+	if err := s.store.User().MarkEmailVerified(t.UserID); err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := s.store.OneTimeToken().DeleteAll(t.UserID, model.PurposeEmailVerification); err != nil {
+		s.logger.Errorf("email verification for %s: %v", t.UserID, err)
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, "Email has been verified")
+}
+
+// HandleEmailVerifyResend emails a new verification link. Like
+// /password/forgot it answers the same for unknown and verified addresses,
+// but it is rate limited per address.
+func (s *server) HandleEmailVerifyResend(c *gin.Context) {
+	type request struct {
+		Email string `json:"email"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	if wait, ok := s.resendLimiter.allow(strings.ToLower(req.Email), time.Now()); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		s.errorCode(c.Writer, c.Request, http.StatusTooManyRequests, "rate_limited", errRateLimited)
+		return
+	}
+
+	u, err := s.store.User().FindByEmail(req.Email)
+	if err == nil && !u.EmailVerified {
+		if err := s.sendEmailVerification(u); err != nil {
+			s.logger.Errorf("email verification for %s: %v", u.ID.Hex(), err)
+		}
+	} else if err != nil && err != store.ErrRecordNotFound {
+		s.logger.Errorf("email verification: %v", err)
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusAccepted, "If the email awaits verification, a link has been sent")
+}
+
+// sendRegistrationNotice tells a registered user that someone tried to
+// register with their email. It is rate limited like verification resends.
+func (s *server) sendRegistrationNotice(u *model.User) error {
+	if _, ok := s.resendLimiter.allow(strings.ToLower(u.Email), time.Now()); !ok {
+		return nil
+	}
+
+	return s.mailer.Send(&mailer.Message{
+		To:      u.Email,
+		Subject: "Your email is already registered",
+		Body: fmt.Sprintf(
+			"Someone tried to register with this email, which already has an account. If it was you, log in or reset your password:\n\n%s\n\nOtherwise, ignore this email.",
+			s.config.PasswordReset.URL,
+		),
+	})
+}
+
+// sendEmailVerification replaces any pending verification token of the
+// user with a new one and mails it.
+func (s *server) sendEmailVerification(u *model.User) error {
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	userID := u.ID.Hex()
+	if err := s.store.OneTimeToken().DeleteAll(userID, model.PurposeEmailVerification); err != nil {
+		return err
+	}
+	if err := s.store.OneTimeToken().Create(&model.OneTimeToken{
+		Hash:      model.HashToken(token),
+		Purpose:   model.PurposeEmailVerification,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.config.EmailVerification.TokenTTL.Duration),
+	}); err != nil {
+		return err
+	}
+
+	link := withQuery(s.config.EmailVerification.URL, url.Values{"token": {token}})
+
+	return s.mailer.Send(&mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Follow this link to verify your email:\n\n%s\n\nThe link expires in %s.",
+			link, s.config.EmailVerification.TokenTTL.Duration,
+		),
+	})
+}
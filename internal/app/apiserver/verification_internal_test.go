@@ -0,0 +1,131 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_EmailVerification(t *testing.T) {
+	m := &testMailer{}
+	s := newServer(teststore.New(), m, NewConfig())
+	payload := map[string]string{"email": "new@example.org", "password": "password"}
+
+	rec := testJSONRequest(s, "/Register", "", payload)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "password")
+	firstToken := testMailedToken(t, m, "token")
+	u, err := s.store.User().FindByEmail("new@example.org")
+	if assert.NoError(t, err) {
+		assert.False(t, u.EmailVerified)
+	}
+
+	login := func() *httptest.ResponseRecorder {
+		return testJSONRequest(s, "/Login", "", payload)
+	}
+	rec = login()
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "email_not_verified")
+
+	rec = testJSONRequest(s, "/verify-email/resend", "", map[string]string{"email": "new@example.org"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Len(t, m.messages, 2)
+	rec = testJSONRequest(s, "/verify-email/resend", "", map[string]string{"email": "new@example.org"})
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	rec = testJSONRequest(s, "/verify-email", "", map[string]string{"token": firstToken})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/verify-email?token="+testMailedToken(t, m, "token"), nil)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = login()
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_HandleUsersCreate_Registered(t *testing.T) {
+	m := &testMailer{}
+	s := newServer(teststore.New(), m, NewConfig())
+	u := model.TestUser(t)
+	assert.NoError(t, s.store.User().Create(u))
+
+	rec := testJSONRequest(s, "/Register", "", map[string]string{"email": "new@example.org", "password": "password"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	created := rec.Body.String()
+
+	rec = testJSONRequest(s, "/Register", "", map[string]string{"email": u.Email, "password": "other-password"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, created, rec.Body.String())
+	if assert.Len(t, m.messages, 2) {
+		assert.Equal(t, u.Email, m.messages[1].To)
+		assert.Contains(t, m.messages[1].Subject, "already registered")
+	}
+
+	// The existing account is left alone.
+	found, err := s.store.User().FindByEmail(u.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, u.EncryptedPassword, found.EncryptedPassword)
+}
+
+func TestServer_EmailVerification_NotRequired(t *testing.T) {
+	config := NewConfig()
+	config.EmailVerification.Required = false
+	s := newServer(teststore.New(), &testMailer{}, config)
+
+	rec := testJSONRequest(s, "/Register", "", map[string]string{"email": "new@example.org", "password": "password"})
+	user := map[string]interface{}{}
+	_ = json.NewDecoder(rec.Body).Decode(&user)
+
+	rec = testJSONRequest(s, "/Login", "", map[string]string{"email": "new@example.org", "password": "password"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(time.Minute, time.Hour, 2)
+	now := time.Now()
+
+	_, ok := l.allow("a", now)
+	assert.True(t, ok)
+	wait, ok := l.allow("a", now.Add(30*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, wait)
+	_, ok = l.allow("b", now.Add(30*time.Second))
+	assert.True(t, ok)
+	_, ok = l.allow("a", now.Add(2*time.Minute))
+	assert.True(t, ok)
+	wait, ok = l.allow("a", now.Add(4*time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, 56*time.Minute, wait)
+	_, ok = l.allow("a", now.Add(time.Hour))
+	assert.True(t, ok)
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	l := newRateLimiter(0, time.Minute, 1)
+	now := time.Now()
+
+	_, ok := l.allow("a", now)
+	assert.True(t, ok)
+	_, ok = l.allow("b", now.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Len(t, l.hits, 2)
+
+	_, ok = l.allow("c", now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.NotContains(t, l.hits, "a")
+	assert.Contains(t, l.hits, "b")
+
+	_, ok = l.allow("c", now.Add(3*time.Minute))
+	assert.True(t, ok)
+	assert.Len(t, l.hits, 1)
+	assert.Contains(t, l.hits, "c")
+}
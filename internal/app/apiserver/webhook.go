@@ -0,0 +1,367 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Headers of a webhook delivery. The signature is computed over the
+// timestamp and the body, see model.Webhook.Sign.
+const (
+	headerWebhookID        = "X-Webhook-Id"
+	headerWebhookEvent     = "X-Webhook-Event"
+	headerWebhookTimestamp = "X-Webhook-Timestamp"
+	headerWebhookSignature = "X-Webhook-Signature"
+
+	maxWebhookError = 256
+)
+
+var (
+	errUnknownWebhook = errors.New("unknown webhook")
+	errWebhookDeleted = errors.New("webhook deleted")
+)
+
+// webhookPayload is the body of a delivery. ID is shared by the deliveries
+// of one event, so receivers can drop duplicates.
+type webhookPayload struct {
+	ID    string            `json:"id"`
+	Event string            `json:"event"`
+	Time  time.Time         `json:"time"`
+	Data  map[string]string `json:"data"`
+}
+
+// webhookEvent returns the webhook event an audit event triggers, if any.
+// Logins count once a session is started, not when a second factor is
+// asked for.
+func webhookEvent(e *model.AuditEvent) string {
+	switch {
+	case e.Outcome == model.AuditSuccess && e.SessionID != "" &&
+		(e.Type == model.AuditLogin || e.Type == model.AuditLoginMFA || e.Type == model.AuditLoginPasswordless):
+		return model.WebhookLogin
+	case e.Outcome == model.AuditSuccess && e.Type == model.AuditLogoutAll:
+		return model.WebhookLogoutAll
+	case e.Outcome == model.AuditFailure && e.Type == model.AuditRefresh && e.Reason == auditRefreshReused:
+		return model.WebhookRefreshReuse
+	}
+
+	return ""
+}
+
+// enqueueWebhooks queues a delivery of the audit event to every webhook
+// subscribed to it. The worker started by runWebhooks sends them.
+func (s *server) enqueueWebhooks(e *model.AuditEvent) {
+	event := webhookEvent(e)
+	if event == "" {
+		return
+	}
+
+	webhooks, err := s.store.Webhook().FindByEvent(event)
+	if err != nil {
+		s.logger.Errorf("webhooks for %s: %v", event, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	id := e.ID
+	if id.IsZero() {
+		id = primitive.NewObjectID()
+	}
+	payload, err := json.Marshal(&webhookPayload{
+		ID:    id.Hex(),
+		Event: event,
+		Time:  e.Time,
+		Data: map[string]string{
+			"actor":      e.Actor,
+			"subject":    e.Subject,
+			"session_id": e.SessionID,
+			"ip":         e.IP,
+			"user_agent": e.UserAgent,
+		},
+	})
+	if err != nil {
+		s.logger.Errorf("webhook payload: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, w := range webhooks {
+		d := &model.WebhookDelivery{
+			WebhookID:   w.ID,
+			Event:       event,
+			Payload:     string(payload),
+			Status:      model.DeliveryPending,
+			NextAttempt: now,
+			Created:     now,
+		}
+		if err := s.store.WebhookDelivery().Create(d); err != nil {
+			s.logger.Errorf("webhook %s delivery: %v", w.ID.Hex(), err)
+		}
+	}
+}
+
+// runWebhooks sends due deliveries every poll interval until stop is
+// closed. Several instances may run it: a delivery is claimed by one of
+// them for the lease.
+func (s *server) runWebhooks(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.config.Webhooks.PollInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.deliverWebhooks(now.UTC())
+		}
+	}
+}
+
+// deliverWebhooks sends the deliveries due at now and returns how many
+// were attempted.
+func (s *server) deliverWebhooks(now time.Time) int {
+	n := 0
+	for {
+		d, err := s.store.WebhookDelivery().Claim(now, s.config.Webhooks.Lease.Duration)
+		if err == store.ErrRecordNotFound {
+			return n
+		}
+		if err != nil {
+			s.logger.Errorf("claim webhook delivery: %v", err)
+			return n
+		}
+		n++
+
+		w, err := s.store.Webhook().Find(d.WebhookID.Hex())
+		switch err {
+		case nil:
+			_, err = s.sendWebhook(w, d.ID.Hex(), d.Event, []byte(d.Payload), now)
+		case store.ErrRecordNotFound:
+			d.Attempts = s.config.Webhooks.MaxAttempts
+			err = errWebhookDeleted
+		default:
+			s.logger.Errorf("webhook %s: %v", d.WebhookID.Hex(), err)
+			continue
+		}
+
+		s.settleDelivery(d, err, now)
+		if err := s.store.WebhookDelivery().Update(d); err != nil {
+			s.logger.Errorf("webhook delivery %s: %v", d.ID.Hex(), err)
+		}
+	}
+}
+
+// settleDelivery records the outcome of an attempt. A failed delivery is
+// retried with exponential backoff and is dead once it has used all its
+// attempts.
+func (s *server) settleDelivery(d *model.WebhookDelivery, err error, now time.Time) {
+	if err == nil {
+		d.Attempts++
+		d.Status = model.DeliveryDelivered
+		d.LastError = ""
+		return
+	}
+
+	if d.Attempts < s.config.Webhooks.MaxAttempts {
+		d.Attempts++
+	}
+	d.LastError = err.Error()
+	if len(d.LastError) > maxWebhookError {
+		d.LastError = d.LastError[:maxWebhookError]
+	}
+	if d.Attempts >= s.config.Webhooks.MaxAttempts {
+		d.Status = model.DeliveryDead
+		return
+	}
+	d.NextAttempt = now.Add(s.webhookBackoff(d.Attempts))
+}
+
+// webhookBackoff returns the delay before the retry that follows the given
+// number of attempts: the base delay doubled on each attempt, capped.
+func (s *server) webhookBackoff(attempts int) time.Duration {
+	delay := s.config.Webhooks.BaseDelay.Duration
+	max := s.config.Webhooks.MaxDelay.Duration
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
+
+// sendWebhook posts a signed payload to the webhook and returns the status
+// code of the response. Any status other than 2xx is an error.
+func (s *server) sendWebhook(w *model.Webhook, id string, event string, payload []byte, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerWebhookID, id)
+	req.Header.Set(headerWebhookEvent, event)
+	req.Header.Set(headerWebhookTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(headerWebhookSignature, w.Sign(now, payload))
+
+	res, err := s.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+// HandleAdminWebhooksCreate registers a webhook. The signing secret is
+// only shown in this response.
+func (s *server) HandleAdminWebhooksCreate(c *gin.Context) {
+	type request struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	req := &request{}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		s.error(c.Writer, c.Request, http.StatusBadRequest, err)
+		return
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	w := &model.Webhook{
+		URL:     req.URL,
+		Events:  req.Events,
+		Secret:  secret,
+		Created: time.Now().UTC(),
+	}
+	if err := s.store.Webhook().Create(w); err != nil {
+		s.error(c.Writer, c.Request, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusCreated, w)
+}
+
+// HandleAdminWebhooksList lists the webhooks without their secrets.
+func (s *server) HandleAdminWebhooksList(c *gin.Context) {
+	webhooks, err := s.store.Webhook().List()
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	for _, w := range webhooks {
+		w.Sanitize()
+	}
+	if webhooks == nil {
+		webhooks = []*model.Webhook{}
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]interface{}{"webhooks": webhooks})
+}
+
+// HandleAdminWebhooksTest sends a ping to the webhook right away and
+// reports the outcome. The ping is not queued nor retried.
+func (s *server) HandleAdminWebhooksTest(c *gin.Context) {
+	w, ok := s.adminFindWebhook(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	id := primitive.NewObjectID().Hex()
+	payload, err := json.Marshal(&webhookPayload{
+		ID:    id,
+		Event: model.WebhookPing,
+		Time:  now,
+		Data:  map[string]string{"webhook_id": w.ID.Hex()},
+	})
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := map[string]interface{}{"delivered": true}
+	status, err := s.sendWebhook(w, id, model.WebhookPing, payload, now)
+	if status != 0 {
+		res["status_code"] = status
+	}
+	if err != nil {
+		res["delivered"] = false
+		res["error"] = err.Error()
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, res)
+}
+
+// HandleAdminWebhooksDelete removes a webhook. Its pending deliveries are
+// dropped by the worker.
+func (s *server) HandleAdminWebhooksDelete(c *gin.Context) {
+	err := s.store.Webhook().Delete(c.Param("id"))
+	if err == store.ErrRecordNotFound {
+		s.error(c.Writer, c.Request, http.StatusNotFound, errUnknownWebhook)
+		return
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleAdminWebhookDeliveriesList lists the deliveries of a webhook with
+// the status query parameter, dead ones by default.
+func (s *server) HandleAdminWebhookDeliveriesList(c *gin.Context) {
+	w, ok := s.adminFindWebhook(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := s.store.WebhookDelivery().FindByWebhook(w.ID.Hex(), c.DefaultQuery("status", model.DeliveryDead))
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*model.WebhookDelivery{}
+	}
+
+	s.respond(c.Writer, c.Request, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+func (s *server) adminFindWebhook(c *gin.Context) (*model.Webhook, bool) {
+	w, err := s.store.Webhook().Find(c.Param("id"))
+	if err == store.ErrRecordNotFound {
+		s.error(c.Writer, c.Request, http.StatusNotFound, errUnknownWebhook)
+		return nil, false
+	}
+	if err != nil {
+		s.error(c.Writer, c.Request, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return w, true
+}
@@ -0,0 +1,200 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+// testWebhookReceiver records the deliveries it gets and answers them with
+// status.
+type testWebhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newTestWebhookReceiver(t *testing.T, status int) *testWebhookReceiver {
+	r := &testWebhookReceiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *testWebhookReceiver) payloads(t *testing.T, secret string) []*webhookPayload {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var payloads []*webhookPayload
+	for i, req := range r.requests {
+		unix, err := strconv.ParseInt(req.Header.Get(headerWebhookTimestamp), 10, 64)
+		assert.NoError(t, err)
+		w := &model.Webhook{Secret: secret}
+		assert.Equal(t, w.Sign(time.Unix(unix, 0), r.bodies[i]), req.Header.Get(headerWebhookSignature))
+
+		p := &webhookPayload{}
+		assert.NoError(t, json.Unmarshal(r.bodies[i], p))
+		assert.Equal(t, p.Event, req.Header.Get(headerWebhookEvent))
+		payloads = append(payloads, p)
+	}
+
+	return payloads
+}
+
+func testAdminToken(t *testing.T, s *server) string {
+	t.Helper()
+
+	admin := model.TestUser(t)
+	admin.Email = "admin@example.org"
+	admin.Username = "admin"
+	admin.Scopes = []string{model.ScopeAdmin}
+	tokens, _ := testLoginUser(t, s, admin)
+
+	return tokens["access_token"]
+}
+
+func TestServer_Webhooks(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+	adminToken := testAdminToken(t, s)
+	receiver := newTestWebhookReceiver(t, http.StatusNoContent)
+
+	rec := testJSONRequest(s, "/admin/webhooks", adminToken, map[string]interface{}{
+		"url":    receiver.URL,
+		"events": []string{"unknown"},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = testJSONRequest(s, "/admin/webhooks", adminToken, map[string]interface{}{
+		"url":    receiver.URL,
+		"events": []string{model.WebhookLogin, model.WebhookRefreshReuse},
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	w := &model.Webhook{}
+	_ = json.NewDecoder(rec.Body).Decode(w)
+	assert.NotEmpty(t, w.Secret)
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	s.ServeHTTP(rec, req)
+	assert.Contains(t, rec.Body.String(), w.ID.Hex())
+	assert.NotContains(t, rec.Body.String(), w.Secret)
+
+	rec = testJSONRequest(s, "/admin/webhooks/"+w.ID.Hex()+"/test", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"delivered":true`)
+
+	u := model.TestUser(t)
+	tokens, _ := testLoginUser(t, s, u)
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, "/Refresh", nil)
+		req.Header.Set(refreshTokenHeader, tokens["refresh_token"])
+		s.ServeHTTP(rec, req)
+	}
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	assert.Equal(t, 2, s.deliverWebhooks(time.Now()))
+	payloads := receiver.payloads(t, w.Secret)
+	if assert.Len(t, payloads, 3) {
+		assert.Equal(t, model.WebhookPing, payloads[0].Event)
+		assert.Equal(t, model.WebhookLogin, payloads[1].Event)
+		assert.Equal(t, u.ID.Hex(), payloads[1].Data["subject"])
+		assert.Equal(t, model.WebhookRefreshReuse, payloads[2].Event)
+		assert.Equal(t, u.ID.Hex(), payloads[2].Data["subject"])
+	}
+	assert.Equal(t, 0, s.deliverWebhooks(time.Now()))
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/webhooks/"+w.ID.Hex(), nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = testJSONRequest(s, "/admin/webhooks/"+w.ID.Hex()+"/test", adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_WebhookRetries(t *testing.T) {
+	config := NewConfig()
+	config.Webhooks.MaxAttempts = 3
+	config.Webhooks.BaseDelay = Duration{time.Minute}
+	config.Webhooks.MaxDelay = Duration{90 * time.Second}
+	s := newServer(teststore.New(), &testMailer{}, config)
+	adminToken := testAdminToken(t, s)
+	receiver := newTestWebhookReceiver(t, http.StatusInternalServerError)
+
+	w := &model.Webhook{URL: receiver.URL, Events: []string{model.WebhookLogin}, Secret: "secret"}
+	if err := s.store.Webhook().Create(w); err != nil {
+		t.Fatal(err)
+	}
+	testLoginUser(t, s, model.TestUser(t))
+
+	now := time.Now()
+	testCases := []struct {
+		at       time.Duration
+		attempts int
+	}{
+		{at: 0, attempts: 1},
+		{at: 59 * time.Second, attempts: 0},
+		{at: time.Minute, attempts: 1},
+		{at: time.Minute + 89*time.Second, attempts: 0},
+		{at: time.Minute + 90*time.Second, attempts: 1},
+		{at: time.Hour, attempts: 0},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.attempts, s.deliverWebhooks(now.Add(tc.at)), tc.at)
+	}
+	assert.Len(t, receiver.payloads(t, w.Secret), 3)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/webhooks/"+w.ID.Hex()+"/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	s.ServeHTTP(rec, req)
+	res := map[string][]*model.WebhookDelivery{}
+	_ = json.NewDecoder(rec.Body).Decode(&res)
+	if assert.Len(t, res["deliveries"], 1) {
+		d := res["deliveries"][0]
+		assert.Equal(t, model.DeliveryDead, d.Status)
+		assert.Equal(t, 3, d.Attempts)
+		assert.Contains(t, d.LastError, "500")
+	}
+}
+
+func TestServer_WebhookBackoff(t *testing.T) {
+	s := newServer(teststore.New(), &testMailer{}, NewConfig())
+
+	testCases := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 30 * time.Second},
+		{attempts: 2, expected: time.Minute},
+		{attempts: 4, expected: 4 * time.Minute},
+		{attempts: 100, expected: time.Hour},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, s.webhookBackoff(tc.attempts), tc.attempts)
+	}
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LogMailer writes messages to a writer instead of sending them. It is
+// meant for local development and tests.
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLog ...
+func NewLog(w io.Writer) *LogMailer {
+	return &LogMailer{
+		w: w,
+	}
+}
+
+// Send ...
+func (m *LogMailer) Send(msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "To: %s\nSubject: %s\n\n%s\n---\n", msg.To, msg.Subject, msg.Body)
+
+	return err
+}
@@ -0,0 +1,21 @@
+package mailer_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/mailer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogMailer_Send(t *testing.T) {
+	b := &bytes.Buffer{}
+	m := mailer.NewLog(b)
+
+	assert.NoError(t, m.Send(&mailer.Message{
+		To:      "user@example.org",
+		Subject: "Subject",
+		Body:    "Body",
+	}))
+	assert.Equal(t, "To: user@example.org\nSubject: Subject\n\nBody\n---\n", b.String())
+}
@@ -0,0 +1,13 @@
+package mailer
+
+// Message ...
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer ...
+type Mailer interface {
+	Send(*Message) error
+}
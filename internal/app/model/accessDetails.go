@@ -3,5 +3,41 @@ package model
 type AccessDetails struct {
 	AccessUUID  string
 	UserID      string
+	ClientID    string
 	RefreshUUID string
+	Roles       []string
+	Scopes      []string
+	AuthTime    int64
+	// ActorID is the admin acting as the user with an impersonation token.
+	ActorID string
+}
+
+// Impersonated reports whether the token was issued to someone acting as
+// the user.
+func (d *AccessDetails) Impersonated() bool {
+	return d.ActorID != ""
+}
+
+// HasRole ...
+func (d *AccessDetails) HasRole(role string) bool {
+	return contains(d.Roles, role)
+}
+
+// HasScopes reports whether every scope in scopes was granted.
+func (d *AccessDetails) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !contains(d.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of audit events.
+const (
+	AuditLogin             = "login"
+	AuditLoginMFA          = "login_mfa"
+	AuditLoginPasswordless = "login_passwordless"
+	AuditRefresh           = "refresh"
+	AuditLogout            = "logout"
+	AuditLogoutAll         = "logout_all"
+	AuditPasswordReset     = "password_reset"
+	AuditImpersonate       = "impersonate"
+	AuditUserDisable       = "user_disable"
+	AuditUserEnable        = "user_enable"
+	AuditForceLogout       = "force_logout"
+)
+
+// Outcomes of audit events.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records a security relevant action. Actor is the user who
+// acted and Subject the user acted on; they differ for admin actions and
+// impersonation.
+type AuditEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type      string             `bson:"type" json:"type"`
+	Outcome   string             `bson:"outcome" json:"outcome"`
+	Actor     string             `bson:"actor,omitempty" json:"actor,omitempty"`
+	Subject   string             `bson:"subject,omitempty" json:"subject,omitempty"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	IP        string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent string             `bson:"userAgent,omitempty" json:"user_agent,omitempty"`
+	SessionID string             `bson:"sessionId,omitempty" json:"session_id,omitempty"`
+	Time      time.Time          `bson:"time" json:"time"`
+	Seq       int64              `bson:"seq,omitempty" json:"seq,omitempty"`
+	PrevHash  string             `bson:"prevHash,omitempty" json:"prev_hash,omitempty"`
+	Hash      string             `bson:"hash,omitempty" json:"hash,omitempty"`
+}
+
+// ComputeHash returns the hex encoded SHA-256 of the event's content and
+// PrevHash, which links the event to the previous one. ID and Hash itself
+// are left out.
+func (e *AuditEvent) ComputeHash() string {
+	b, _ := json.Marshal([]interface{}{
+		e.Seq,
+		e.PrevHash,
+		e.Type,
+		e.Outcome,
+		e.Actor,
+		e.Subject,
+		e.Reason,
+		e.IP,
+		e.UserAgent,
+		e.SessionID,
+		e.Time.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditCheckpoint vouches for the audit log up to the event Seq with a
+// token signed by the service.
+type AuditCheckpoint struct {
+	Seq   int64     `bson:"_id" json:"seq"`
+	Hash  string    `bson:"hash" json:"hash"`
+	Time  time.Time `bson:"time" json:"time"`
+	Token string    `bson:"token" json:"token"`
+}
+
+// AuditCheckpointClaims are the claims of the checkpoint token.
+type AuditCheckpointClaims struct {
+	RegisteredClaims
+	Seq  int64  `json:"seq"`
+	Hash string `json:"audit_hash"`
+}
+
+// Valid ...
+func (c *AuditCheckpointClaims) Valid() error {
+	if c.Seq <= 0 || c.Hash == "" {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+// AuditFilter selects a page of audit events, newest first. UserID matches
+// the actor or the subject; zero times leave the range open.
+type AuditFilter struct {
+	UserID string
+	Type   string
+	From   time.Time
+	To     time.Time
+	Offset int
+	Limit  int
+}
+
+// Matches reports whether the event passes the filter, ignoring the page.
+func (f *AuditFilter) Matches(e *AuditEvent) bool {
+	switch {
+	case f.UserID != "" && e.Actor != f.UserID && e.Subject != f.UserID:
+		return false
+	case f.Type != "" && e.Type != f.Type:
+		return false
+	case !f.From.IsZero() && e.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.Time.Before(f.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audience is the aud claim. It is encoded as an array of strings but also
+// accepts the single string form allowed by RFC 7519.
+type Audience []string
+
+// UnmarshalJSON ...
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+
+	return nil
+}
+
+// Contains reports whether the audience names at least one of audiences.
+func (a Audience) Contains(audiences ...string) bool {
+	for _, aud := range a {
+		for _, expected := range audiences {
+			if aud == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RegisteredClaims are the claims registered by RFC 7519 that both token
+// types carry.
+type RegisteredClaims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+}
+
+// Verify checks the time based claims, allowing for leeway of clock skew,
+// and the issuer and audience when they are not empty.
+func (c *RegisteredClaims) Verify(now time.Time, leeway time.Duration, issuer string, audiences []string) error {
+	if c.ExpiresAt == 0 || now.Add(-leeway).Unix() > c.ExpiresAt {
+		return ErrTokenExpired
+	}
+	if now.Add(leeway).Unix() < c.NotBefore || now.Add(leeway).Unix() < c.IssuedAt {
+		return ErrTokenNotValidYet
+	}
+	if issuer != "" && c.Issuer != issuer {
+		return ErrTokenInvalidIssuer
+	}
+	if len(audiences) > 0 && !c.Audience.Contains(audiences...) {
+		return ErrTokenInvalidAudience
+	}
+
+	return nil
+}
+
+// AccessClaims ...
+type AccessClaims struct {
+	RegisteredClaims
+	Authorized bool     `json:"authorized"`
+	AccessUUID string   `json:"access_uuid"`
+	UserID     string   `json:"user_id,omitempty"`
+	ClientID   string   `json:"client_id,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	Scope      string   `json:"scope,omitempty"`
+	AuthTime   int64    `json:"auth_time,omitempty"`
+	Act        *Actor   `json:"act,omitempty"`
+}
+
+// Actor is the RFC 8693 act claim: the party acting on behalf of the
+// subject of the token, such as an admin impersonating a user. A chain of
+// delegation nests further actors.
+type Actor struct {
+	Subject string `json:"sub"`
+	Act     *Actor `json:"act,omitempty"`
+}
+
+// ActorID returns the subject of the act claim, if any.
+func (c *AccessClaims) ActorID() string {
+	if c.Act == nil {
+		return ""
+	}
+	return c.Act.Subject
+}
+
+// Valid checks that the claims the handlers rely on are present. Tokens
+// issued to a client on its own behalf carry client_id instead of user_id.
+func (c *AccessClaims) Valid() error {
+	if c.AccessUUID == "" || (c.UserID == "" && c.ClientID == "") {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+// RefreshClaims ...
+type RefreshClaims struct {
+	RegisteredClaims
+	RefreshUUID string `json:"refresh_uuid"`
+	UserID      string `json:"user_id"`
+	ClientID    string `json:"client_id,omitempty"`
+	AuthTime    int64  `json:"auth_time"`
+	// Scope holds the scopes granted with the session, so that a refresh
+	// never widens them.
+	Scope string `json:"scope,omitempty"`
+	// ClientAuthenticated keeps the lifetimes of an authenticated client
+	// over refreshes.
+	ClientAuthenticated bool `json:"client_auth,omitempty"`
+}
+
+// Valid checks that the claims the handlers rely on are present.
+func (c *RefreshClaims) Valid() error {
+	if c.RefreshUUID == "" || c.UserID == "" {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+// IDClaims are the claims of an OpenID Connect ID token.
+type IDClaims struct {
+	RegisteredClaims
+	AuthTime        int64  `json:"auth_time,omitempty"`
+	Nonce           string `json:"nonce,omitempty"`
+	AccessTokenHash string `json:"at_hash,omitempty"`
+	Email           string `json:"email,omitempty"`
+	EmailVerified   *bool  `json:"email_verified,omitempty"`
+}
+
+// SetEmail adds the email of u and whether it is verified when the email
+// scope was granted, as UserInfo does.
+func (c *IDClaims) SetEmail(u *User, scopes []string) {
+	if !contains(scopes, ScopeEmail) || u.Email == "" {
+		return
+	}
+
+	verified := u.EmailVerified
+	c.Email = u.Email
+	c.EmailVerified = &verified
+}
+
+// Valid ...
+func (c *IDClaims) Valid() error {
+	if c.Subject == "" {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+// ChallengeClaims are the claims of the token that carries a login from the
+// first to the second authentication step.
+type ChallengeClaims struct {
+	RegisteredClaims
+	UserID   string `json:"user_id"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// Valid ...
+func (c *ChallengeClaims) Valid() error {
+	if c.UserID == "" {
+		return ErrTokenMalformed
+	}
+	return nil
+}
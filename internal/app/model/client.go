@@ -0,0 +1,94 @@
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Grant types a client may be allowed to use.
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// Client is an application registered to obtain tokens on behalf of users
+// or, for confidential clients, on its own behalf.
+type Client struct {
+	ID            string   `bson:"_id" json:"client_id"`
+	Name          string   `bson:"name" json:"name"`
+	Secret        string   `bson:"-" json:"client_secret,omitempty"`
+	SecretHash    string   `bson:"secret_hash,omitempty" json:"-"`
+	RedirectURIs  []string `bson:"redirect_uris" json:"redirect_uris"`
+	AllowedScopes []string `bson:"allowed_scopes" json:"allowed_scopes"`
+	GrantTypes    []string `bson:"grant_types,omitempty" json:"grant_types,omitempty"`
+	// TokenTTL is the lifetime of the access tokens issued to the client on
+	// its own behalf, in seconds. Zero keeps the configured lifetime.
+	TokenTTL int64 `bson:"token_ttl,omitempty" json:"token_ttl,omitempty"`
+}
+
+// Validate ...
+func (c *Client) Validate() error {
+	return validation.ValidateStruct(
+		c,
+		validation.Field(&c.ID, validation.Required),
+		validation.Field(&c.Secret, validation.Length(32, 128)),
+		validation.Field(&c.RedirectURIs, validation.Each(is.URL)),
+		validation.Field(&c.GrantTypes, validation.Each(validation.In(GrantTypeAuthorizationCode, GrantTypeClientCredentials))),
+		validation.Field(&c.TokenTTL, validation.Min(0)),
+	)
+}
+
+// BeforeCreate ...
+func (c *Client) BeforeCreate() error {
+	if len(c.Secret) > 0 {
+		enc, err := encryptString(c.Secret)
+		if err != nil {
+			return err
+		}
+
+		c.SecretHash = enc
+	}
+
+	return nil
+}
+
+// Sanitize ...
+func (c *Client) Sanitize() {
+	c.Secret = ""
+}
+
+// Confidential reports whether the client has a secret to authenticate with.
+func (c *Client) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// CompareSecret ...
+func (c *Client) CompareSecret(secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(c.SecretHash), []byte(secret)) == nil
+}
+
+// AllowsGrantType reports whether the client may use grantType. Clients
+// registered without grant types may only use the authorization code grant.
+func (c *Client) AllowsGrantType(grantType string) bool {
+	if len(c.GrantTypes) == 0 {
+		return grantType == GrantTypeAuthorizationCode
+	}
+	return contains(c.GrantTypes, grantType)
+}
+
+// HasRedirectURI reports whether uri is registered. Redirect URIs are
+// compared as exact strings.
+func (c *Client) HasRedirectURI(uri string) bool {
+	return contains(c.RedirectURIs, uri)
+}
+
+// AllowsScopes reports whether the client may request every one of scopes.
+func (c *Client) AllowsScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !contains(c.AllowedScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
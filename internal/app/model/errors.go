@@ -0,0 +1,22 @@
+package model
+
+import "errors"
+
+var (
+	// ErrTokenMissing ...
+	ErrTokenMissing = errors.New("token missing")
+	// ErrTokenMalformed ...
+	ErrTokenMalformed = errors.New("token malformed")
+	// ErrTokenSignatureInvalid ...
+	ErrTokenSignatureInvalid = errors.New("token signature invalid")
+	// ErrTokenWrongType ...
+	ErrTokenWrongType = errors.New("wrong token type")
+	// ErrTokenExpired ...
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotValidYet ...
+	ErrTokenNotValidYet = errors.New("token not valid yet")
+	// ErrTokenInvalidIssuer ...
+	ErrTokenInvalidIssuer = errors.New("token issuer invalid")
+	// ErrTokenInvalidAudience ...
+	ErrTokenInvalidAudience = errors.New("token audience invalid")
+)
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Purposes of one-time tokens. A token is only accepted for the purpose it
+// was issued for.
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeMagicLink         = "magic_link"
+	PurposeLoginCode         = "login_code"
+	PurposeMFAChallenge      = "mfa_challenge"
+)
+
+// OneTimeToken is a single-use token sent to a user, for instance in a
+// password reset link. Only the hash of the token is stored.
+type OneTimeToken struct {
+	Token     string    `bson:"-"`
+	Hash      string    `bson:"_id"`
+	Purpose   string    `bson:"purpose"`
+	UserID    string    `bson:"userId"`
+	ClientID  string    `bson:"clientId,omitempty"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// Expired ...
+func (t *OneTimeToken) Expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
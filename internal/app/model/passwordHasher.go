@@ -0,0 +1,178 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrUnknownPasswordHash is returned for an encoded password hash in none
+// of the supported formats.
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords and verifies them against stored hashes.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash.
+	Verify(encoded string, password string) (bool, error)
+	// NeedsRehash reports whether the encoded hash should be replaced by
+	// one made with the current algorithm and parameters.
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2Params are the parameters of argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Time       uint32
+	Memory     uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106.
+var DefaultArgon2Params = Argon2Params{
+	Time:       3,
+	Memory:     64 * 1024,
+	Threads:    4,
+	KeyLength:  32,
+	SaltLength: 16,
+}
+
+// passwordHasher hashes with argon2id in the PHC string format
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+//
+// and also verifies the hashes of the old system: bcrypt, and PBKDF2 in the
+// format pbkdf2_<sha1|sha256|sha512>$<iterations>$<salt>$<base64 hash>.
+type passwordHasher struct {
+	params Argon2Params
+}
+
+// NewPasswordHasher ...
+func NewPasswordHasher(params Argon2Params) PasswordHasher {
+	return &passwordHasher{params: params}
+}
+
+// Hash ...
+func (h *passwordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Time, h.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify ...
+func (h *passwordHasher) Verify(encoded string, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(encoded, "pbkdf2_"):
+		return verifyPBKDF2(encoded, password)
+	}
+
+	return false, ErrUnknownPasswordHash
+}
+
+// NeedsRehash ...
+func (h *passwordHasher) NeedsRehash(encoded string) bool {
+	params, salt, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params != h.params || uint32(len(salt)) != h.params.SaltLength
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	params := Argon2Params{}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+func verifyPBKDF2(encoded string, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return false, ErrUnknownPasswordHash
+	}
+
+	var h func() hash.Hash
+	switch parts[0] {
+	case "pbkdf2_sha1":
+		h = sha1.New
+	case "pbkdf2_sha256":
+		h = sha256.New
+	case "pbkdf2_sha512":
+		h = sha512.New
+	default:
+		return false, ErrUnknownPasswordHash
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, ErrUnknownPasswordHash
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrUnknownPasswordHash
+	}
+
+	other := pbkdf2.Key([]byte(password), []byte(parts[2]), iterations, len(key), h)
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
@@ -0,0 +1,64 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	params := model.Argon2Params{Time: 1, Memory: 1024, Threads: 1, KeyLength: 32, SaltLength: 16}
+	h := model.NewPasswordHasher(params)
+
+	argon2id, err := h.Hash("password")
+	assert.NoError(t, err)
+	assert.Contains(t, argon2id, "$argon2id$v=19$m=1024,t=1,p=1$")
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+
+	testCases := []struct {
+		name        string
+		encoded     string
+		isValid     bool
+		needsRehash bool
+	}{
+		{
+			name:    "argon2id",
+			encoded: argon2id,
+			isValid: true,
+		},
+		{
+			name:        "bcrypt",
+			encoded:     string(bcryptHash),
+			isValid:     true,
+			needsRehash: true,
+		},
+		{
+			name:        "pbkdf2",
+			encoded:     "pbkdf2_sha256$1000$salt1234$GBMH7yNF3CH2y6aUf/jCqV1Np6jWrzmBYWn7gEQluPU=",
+			isValid:     true,
+			needsRehash: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := h.Verify(tc.encoded, "password")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.isValid, ok)
+
+			ok, err = h.Verify(tc.encoded, "wrong")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.Equal(t, tc.needsRehash, h.NeedsRehash(tc.encoded))
+		})
+	}
+
+	params.Time = 2
+	assert.True(t, model.NewPasswordHasher(params).NeedsRehash(argon2id))
+
+	_, err = h.Verify("md5$abc", "password")
+	assert.Equal(t, model.ErrUnknownPasswordHash, err)
+}
@@ -0,0 +1,209 @@
+package model
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// Errors of the password policy.
+var (
+	ErrPasswordTooShort   = errors.New("is too short")
+	ErrPasswordTooLong    = errors.New("is too long")
+	ErrPasswordNoUpper    = errors.New("must contain an upper case letter")
+	ErrPasswordNoLower    = errors.New("must contain a lower case letter")
+	ErrPasswordNoDigit    = errors.New("must contain a digit")
+	ErrPasswordNoSymbol   = errors.New("must contain a symbol")
+	ErrPasswordBannedWord = errors.New("must not contain a common word")
+	ErrPasswordSimilar    = errors.New("must not resemble the email or the name")
+	ErrPasswordBreached   = errors.New("appears in a known data breach")
+)
+
+const (
+	minSimilarityAttribute  = 3
+	breachedPasswordPrefix  = 5
+	breachedPasswordFileExt = ".txt"
+)
+
+// BreachedPasswords tells whether a password is known to have leaked.
+type BreachedPasswords interface {
+	Contains(password string) (bool, error)
+}
+
+// PasswordPolicy is the set of rules a new password must follow.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	BannedWords   []string
+	Breached      BreachedPasswords
+}
+
+// DefaultPasswordPolicy ...
+var DefaultPasswordPolicy = &PasswordPolicy{
+	MinLength: 6,
+	MaxLength: 100,
+}
+
+// Validate checks a new password on its own, before it is set on a user.
+// The checks against the user's attributes are left to
+// User.ValidatePassword.
+func (p *PasswordPolicy) Validate(password string) error {
+	return validation.Validate(password, validation.Required, p.Rule(nil))
+}
+
+// Rule returns a validation rule that checks a password against the
+// policy. When u is not nil the password must also not resemble the
+// user's email or names.
+func (p *PasswordPolicy) Rule(u *User) validation.Rule {
+	return validation.By(func(value interface{}) error {
+		password, _ := value.(string)
+		if password == "" {
+			return nil
+		}
+
+		return p.check(password, u)
+	})
+}
+
+func (p *PasswordPolicy) check(password string, u *User) error {
+	n := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && n < p.MinLength {
+		return ErrPasswordTooShort
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		return ErrPasswordTooLong
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r), unicode.IsSymbol(r), unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !upper:
+		return ErrPasswordNoUpper
+	case p.RequireLower && !lower:
+		return ErrPasswordNoLower
+	case p.RequireDigit && !digit:
+		return ErrPasswordNoDigit
+	case p.RequireSymbol && !symbol:
+		return ErrPasswordNoSymbol
+	}
+
+	normalized := normalizeForComparison(password)
+	for _, word := range p.BannedWords {
+		if w := normalizeForComparison(word); w != "" && strings.Contains(normalized, w) {
+			return ErrPasswordBannedWord
+		}
+	}
+
+	if u != nil && similarToUser(normalized, u) {
+		return ErrPasswordSimilar
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return validation.NewInternalError(err)
+		}
+		if breached {
+			return ErrPasswordBreached
+		}
+	}
+
+	return nil
+}
+
+// similarToUser reports whether the password contains, or is contained in,
+// the local part of the email, the username or one of the names.
+func similarToUser(normalized string, u *User) bool {
+	attributes := []string{u.Username, u.FirstName, u.LastName, u.FullName}
+	if i := strings.Index(u.Email, "@"); i > 0 {
+		attributes = append(attributes, u.Email[:i])
+	}
+
+	for _, attribute := range attributes {
+		a := normalizeForComparison(attribute)
+		if len(a) < minSimilarityAttribute {
+			continue
+		}
+		if strings.Contains(normalized, a) || strings.Contains(a, normalized) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeForComparison lower cases s and drops everything but letters and
+// digits, so that "J.Smith-1" compares like "jsmith1".
+func normalizeForComparison(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// BreachedPasswordDir looks passwords up in a local copy of a k-anonymity
+// breached password dataset: one file per 5 character prefix of the upper
+// case hex SHA-1 hash, named like "5BAA6.txt", with one
+// "<35 character hash suffix>:<count>" line per password.
+type BreachedPasswordDir struct {
+	Dir string
+}
+
+// NewBreachedPasswordDir ...
+func NewBreachedPasswordDir(dir string) *BreachedPasswordDir {
+	return &BreachedPasswordDir{Dir: dir}
+}
+
+// Contains ...
+func (d *BreachedPasswordDir) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachedPasswordPrefix], hash[breachedPasswordPrefix:]
+
+	f, err := os.Open(filepath.Join(d.Dir, prefix+breachedPasswordFileExt))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,84 @@
+package model_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// SHA-1 of "P@ssw0rd!" is 076D3E6C4B9F654B5B220B9045B7458AB6B4CBC6.
+	data := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" +
+		"E6C4B9F654B5B220B9045B7458AB6B4CBC6:42\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "076D3.txt"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &model.PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     64,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		BannedWords:   []string{"qwerty"},
+		Breached:      model.NewBreachedPasswordDir(dir),
+	}
+	u := &model.User{
+		Email:     "jsmith@example.org",
+		FirstName: "John",
+	}
+
+	testCases := []struct {
+		password string
+		err      error
+	}{
+		{password: "", err: nil},
+		{password: "Ab1!", err: model.ErrPasswordTooShort},
+		{password: "ab1!ab1!ab1!", err: model.ErrPasswordNoUpper},
+		{password: "AB1!AB1!AB1!", err: model.ErrPasswordNoLower},
+		{password: "Abc!Abc!Abc!", err: model.ErrPasswordNoDigit},
+		{password: "Abc1Abc1Abc1", err: model.ErrPasswordNoSymbol},
+		{password: "My-QWERTY-1", err: model.ErrPasswordBannedWord},
+		{password: "J.Smith-2020", err: model.ErrPasswordSimilar},
+		{password: "P@ssw0rd!", err: model.ErrPasswordBreached},
+		{password: "Tr0ub4dor&3x", err: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.password, func(t *testing.T) {
+			assert.Equal(t, tc.err, validation.Validate(tc.password, policy.Rule(u)))
+		})
+	}
+
+	assert.NoError(t, validation.Validate("J.Smith-2020", policy.Rule(nil)))
+}
+
+func TestUser_ValidatePassword(t *testing.T) {
+	policy := &model.PasswordPolicy{MinLength: 8}
+
+	u := model.TestUser(t)
+	u.Password = "password"
+	assert.NoError(t, u.ValidatePassword(policy))
+
+	u.Password = "user-1234"
+	assert.Error(t, u.ValidatePassword(policy))
+
+	u.Password = "short"
+	assert.Error(t, u.ValidatePassword(policy))
+	assert.Error(t, policy.Validate("short"))
+
+	u.Password = ""
+	assert.Error(t, u.ValidatePassword(policy))
+}
@@ -0,0 +1,27 @@
+package model
+
+import "strings"
+
+// ScopeAdmin grants access to the admin API.
+const ScopeAdmin = "admin"
+
+// JoinScopes encodes scopes as the space-delimited scope claim.
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// SplitScopes decodes a space-delimited scope claim.
+func SplitScopes(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// FilterScopes returns the scopes that are also in allowed.
+func FilterScopes(scopes []string, allowed []string) []string {
+	var res []string
+	for _, scope := range scopes {
+		if contains(allowed, scope) {
+			res = append(res, scope)
+		}
+	}
+	return res
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// Session is a refresh session of a user, one per issued refresh token.
+type Session struct {
+	RefreshUUID string    `bson:"refreshToken" json:"id"`
+	UserID      string    `bson:"userId" json:"user_id"`
+	ExpiresAt   time.Time `bson:"expiresAt" json:"expires_at"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "testing"
+
+// TestUser ...
+func TestUser(t *testing.T) *User {
+	t.Helper()
+
+	u := &User{
+		Email:         "user@example.org",
+		EmailVerified: true,
+		Password:      "password",
+	}
+	if err := u.EncryptPassword(NewPasswordHasher(DefaultArgon2Params)); err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
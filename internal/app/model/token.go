@@ -1,5 +1,11 @@
 package model
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
 type TokenDetails struct {
 	AccessToken  string
 	RefreshToken string
@@ -7,4 +13,41 @@ type TokenDetails struct {
 	RefreshUuid  string
 	AtExpires    int64
 	RtExpires    int64
+	IDToken      string
+}
+
+// TokenRequest describes whom a token pair is issued to. A request without
+// UserID is made by a client on its own behalf.
+type TokenRequest struct {
+	UserID   string
+	ClientID string
+	// ClientAuthenticated is set when the client proved its identity, and
+	// its lifetime override may apply. The client_id of a login is not.
+	ClientAuthenticated bool
+	Roles               []string
+	Scopes              []string
+	AuthTime            int64
+	// AccessTTL overrides the configured access token lifetime when set.
+	AccessTTL time.Duration
+	// AccessOnly issues the access token without a refresh token.
+	AccessOnly bool
+	// ActorID is set when someone else acts as the user, and becomes the
+	// act claim.
+	ActorID string
+}
+
+// Subject returns the sub claim of the tokens: the user, or the client when
+// no user is involved.
+func (r *TokenRequest) Subject() string {
+	if r.UserID == "" {
+		return r.ClientID
+	}
+	return r.UserID
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token, which is
+// what gets stored instead of the token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
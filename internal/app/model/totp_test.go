@@ -0,0 +1,47 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238 appendix B, truncated to 6 digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	testCases := []struct {
+		time int64
+		code string
+	}{
+		{time: 59, code: "287082"},
+		{time: 1111111109, code: "081804"},
+		{time: 1234567890, code: "005924"},
+		{time: 2000000000, code: "279037"},
+	}
+
+	for _, tc := range testCases {
+		code, err := model.TOTPCode(secret, model.TOTPStep(time.Unix(tc.time, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.code, code)
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := model.NewTOTPSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	step := model.TOTPStep(now)
+	for _, s := range []int64{step - 1, step, step + 1} {
+		code, _ := model.TOTPCode(secret, s)
+		matched, ok := model.VerifyTOTP(secret, code, now)
+		assert.True(t, ok)
+		assert.Equal(t, s, matched)
+	}
+
+	code, _ := model.TOTPCode(secret, step+2)
+	_, ok := model.VerifyTOTP(secret, code, now)
+	assert.False(t, ok)
+}
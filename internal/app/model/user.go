@@ -0,0 +1,137 @@
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User ...
+type User struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email             string             `bson:"email" json:"email"`
+	EmailVerified     bool               `bson:"email_verified" json:"email_verified"`
+	Password          string             `bson:"-" json:"password,omitempty"`
+	EncryptedPassword string             `bson:"password" json:"-"`
+	Username          string             `bson:"username,omitempty" json:"username,omitempty"`
+	FirstName         string             `bson:"firstname,omitempty" json:"firstname,omitempty"`
+	LastName          string             `bson:"lastname,omitempty" json:"lastname,omitempty"`
+	FullName          string             `bson:"fullname,omitempty" json:"fullname,omitempty"`
+	Roles             []string           `bson:"roles,omitempty" json:"roles,omitempty"`
+	Scopes            []string           `bson:"scopes,omitempty" json:"scopes,omitempty"`
+	TOTPSecret        string             `bson:"totp_secret,omitempty" json:"-"`
+	TOTPEnabled       bool               `bson:"totp_enabled,omitempty" json:"totp_enabled,omitempty"`
+	TOTPLastStep      int64              `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes     []string           `bson:"recovery_codes,omitempty" json:"-"`
+	MFAFailures       int                `bson:"mfa_failures,omitempty" json:"-"`
+	MFALastFailure    time.Time          `bson:"mfa_last_failure,omitempty" json:"-"`
+	Status            string             `bson:"status,omitempty" json:"status,omitempty"`
+	Created           time.Time          `bson:"created" json:"created"`
+}
+
+// Statuses of a user account.
+const (
+	UserStatusActive              = "active"
+	UserStatusDisabled            = "disabled"
+	UserStatusLocked              = "locked"
+	UserStatusPendingVerification = "pending_verification"
+)
+
+// Validate ...
+func (u *User) Validate() error {
+	return validation.ValidateStruct(
+		u,
+		validation.Field(&u.Email, validation.Required, is.Email),
+		validation.Field(&u.EncryptedPassword, validation.Required),
+	)
+}
+
+// ValidatePassword checks the password of the user against the policy,
+// including that it does not resemble the user's email or names.
+func (u *User) ValidatePassword(p *PasswordPolicy) error {
+	return validation.ValidateStruct(
+		u,
+		validation.Field(&u.Password, validation.Required, p.Rule(u)),
+	)
+}
+
+// EncryptPassword hashes the password of the user with h.
+func (u *User) EncryptPassword(h PasswordHasher) error {
+	enc, err := h.Hash(u.Password)
+	if err != nil {
+		return err
+	}
+
+	u.EncryptedPassword = enc
+
+	return nil
+}
+
+// BeforeCreate ...
+func (u *User) BeforeCreate() error {
+	if u.Status == "" {
+		u.Status = UserStatusActive
+	}
+	if u.Created.IsZero() {
+		u.Created = time.Now()
+	}
+
+	return nil
+}
+
+// MFALocked reports whether the user has failed maxAttempts second-factor
+// codes in a row and the last failure is less than lockout ago.
+func (u *User) MFALocked(now time.Time, maxAttempts int, lockout time.Duration) bool {
+	return maxAttempts > 0 && u.MFAFailures >= maxAttempts && now.Sub(u.MFALastFailure) < lockout
+}
+
+// Active reports whether the user may be issued tokens. Users stored
+// before the status field was introduced count as active.
+func (u *User) Active() bool {
+	return u.Status == "" || u.Status == UserStatusActive
+}
+
+// HasRole ...
+func (u *User) HasRole(role string) bool {
+	return contains(u.Roles, role)
+}
+
+// GrantableScopes returns the scopes a client may obtain on behalf of the
+// user: the user's own scopes and the OpenID Connect ones.
+func (u *User) GrantableScopes() []string {
+	return append(append([]string{}, u.Scopes...), OIDCScopes...)
+}
+
+// HasRecoveryCode reports whether the user has the recovery code hash.
+func (u *User) HasRecoveryCode(codeHash string) bool {
+	return contains(u.RecoveryCodes, codeHash)
+}
+
+// Sanitize ...
+func (u *User) Sanitize() {
+	u.Password = ""
+}
+
+// ComparePassword ...
+func (u *User) ComparePassword(h PasswordHasher, password string) bool {
+	ok, _ := h.Verify(u.EncryptedPassword, password)
+	return ok
+}
+
+// PasswordNeedsRehash reports whether the stored password hash is outdated
+// and should be replaced once the password is known again.
+func (u *User) PasswordNeedsRehash(h PasswordHasher) bool {
+	return h.NeedsRehash(u.EncryptedPassword)
+}
+
+func encryptString(s string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(s), bcrypt.MinCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,10 @@
+package model
+
+// UserFilter selects a page of users. Query matches the beginning of the
+// username or the email, case-insensitively.
+type UserFilter struct {
+	Query  string
+	Status string
+	Offset int
+	Limit  int
+}
@@ -0,0 +1,39 @@
+package model
+
+// OpenID Connect scopes.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OIDCScopes ...
+var OIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// UserInfo returns the claims about u released for scopes, following the
+// scope to claim mapping of OpenID Connect Core section 5.4.
+func UserInfo(u *User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": u.ID.Hex(),
+	}
+
+	if contains(scopes, ScopeProfile) {
+		setClaim(claims, "name", u.FullName)
+		setClaim(claims, "given_name", u.FirstName)
+		setClaim(claims, "family_name", u.LastName)
+		setClaim(claims, "preferred_username", u.Username)
+	}
+
+	if contains(scopes, ScopeEmail) && u.Email != "" {
+		claims["email"] = u.Email
+		claims["email_verified"] = u.EmailVerified
+	}
+
+	return claims
+}
+
+func setClaim(claims map[string]interface{}, name string, value string) {
+	if value != "" {
+		claims[name] = value
+	}
+}
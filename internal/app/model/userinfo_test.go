@@ -0,0 +1,58 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserInfo(t *testing.T) {
+	u := model.TestUser(t)
+	u.Username = "jane"
+	u.EmailVerified = false
+
+	testCases := []struct {
+		name   string
+		scopes []string
+		claims map[string]interface{}
+	}{
+		{
+			name:   "openid",
+			scopes: []string{"openid"},
+			claims: map[string]interface{}{"sub": u.ID.Hex()},
+		},
+		{
+			name:   "profile",
+			scopes: []string{"openid", "profile"},
+			claims: map[string]interface{}{"sub": u.ID.Hex(), "preferred_username": "jane"},
+		},
+		{
+			name:   "unverified email",
+			scopes: []string{"openid", "email"},
+			claims: map[string]interface{}{"sub": u.ID.Hex(), "email": u.Email, "email_verified": false},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.claims, model.UserInfo(u, tc.scopes))
+		})
+	}
+}
+
+func TestIDClaims_SetEmail(t *testing.T) {
+	u := model.TestUser(t)
+	u.EmailVerified = false
+
+	c := &model.IDClaims{}
+	c.SetEmail(u, []string{"openid"})
+	assert.Empty(t, c.Email)
+	assert.Nil(t, c.EmailVerified)
+
+	c.SetEmail(u, []string{"openid", "email"})
+	assert.Equal(t, u.Email, c.Email)
+	if assert.NotNil(t, c.EmailVerified) {
+		assert.False(t, *c.EmailVerified)
+	}
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Events a webhook can subscribe to.
+const (
+	WebhookLogin        = "login"
+	WebhookLogoutAll    = "logout_all"
+	WebhookRefreshReuse = "refresh_reuse"
+	WebhookPing         = "ping"
+)
+
+var httpURL = regexp.MustCompile(`^https?://`)
+
+// WebhookEvents are the events a webhook can subscribe to.
+var WebhookEvents = []string{WebhookLogin, WebhookLogoutAll, WebhookRefreshReuse}
+
+// Webhook is a subscription of another system to security events. Deliveries
+// are signed with Secret, which is kept because the signature needs it.
+type Webhook struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL     string             `bson:"url" json:"url"`
+	Events  []string           `bson:"events" json:"events"`
+	Secret  string             `bson:"secret" json:"secret,omitempty"`
+	Created time.Time          `bson:"created" json:"created"`
+}
+
+// Validate ...
+func (w *Webhook) Validate() error {
+	events := make([]interface{}, len(WebhookEvents))
+	for i, event := range WebhookEvents {
+		events[i] = event
+	}
+
+	return validation.ValidateStruct(
+		w,
+		validation.Field(&w.URL, validation.Required, is.URL, validation.Match(httpURL)),
+		validation.Field(&w.Events, validation.Required, validation.Each(validation.In(events...))),
+		validation.Field(&w.Secret, validation.Required),
+	)
+}
+
+// Sanitize ...
+func (w *Webhook) Sanitize() {
+	w.Secret = ""
+}
+
+// Subscribes reports whether the webhook wants the event.
+func (w *Webhook) Subscribes(event string) bool {
+	return event == WebhookPing || contains(w.Events, event)
+}
+
+// Sign returns the signature of a delivery: the hex encoded HMAC-SHA256,
+// keyed with the secret, of the Unix timestamp, a dot and the body.
+func (w *Webhook) Sign(timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Statuses of a webhook delivery. Pending deliveries are retried until they
+// are delivered or run out of attempts and become dead.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery is an event queued for a webhook.
+type WebhookDelivery struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID   primitive.ObjectID `bson:"webhookId" json:"webhook_id"`
+	Event       string             `bson:"event" json:"event"`
+	Payload     string             `bson:"payload" json:"payload"`
+	Status      string             `bson:"status" json:"status"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	NextAttempt time.Time          `bson:"nextAttempt" json:"next_attempt"`
+	LastError   string             `bson:"lastError,omitempty" json:"last_error,omitempty"`
+	Created     time.Time          `bson:"created" json:"created"`
+}
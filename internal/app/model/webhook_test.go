@@ -0,0 +1,58 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		webhook *model.Webhook
+		isValid bool
+	}{
+		{
+			name:    "valid",
+			webhook: &model.Webhook{URL: "https://example.org/hook", Events: []string{model.WebhookLogin}, Secret: "secret"},
+			isValid: true,
+		},
+		{
+			name:    "not http",
+			webhook: &model.Webhook{URL: "ftp://example.org/hook", Events: []string{model.WebhookLogin}, Secret: "secret"},
+			isValid: false,
+		},
+		{
+			name:    "no events",
+			webhook: &model.Webhook{URL: "https://example.org/hook", Secret: "secret"},
+			isValid: false,
+		},
+		{
+			name:    "unknown event",
+			webhook: &model.Webhook{URL: "https://example.org/hook", Events: []string{model.WebhookPing}, Secret: "secret"},
+			isValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.isValid {
+				assert.NoError(t, tc.webhook.Validate())
+			} else {
+				assert.Error(t, tc.webhook.Validate())
+			}
+		})
+	}
+}
+
+func TestWebhook_Sign(t *testing.T) {
+	w := &model.Webhook{Secret: "secret"}
+	// HMAC-SHA256("secret", "1600000000.{}")
+	assert.Equal(
+		t,
+		"sha256=1e56a11da123b137c26fa37b7c222060bdf22988aa9b3248c31244f8b2ef4a28",
+		w.Sign(time.Unix(1600000000, 0), []byte("{}")),
+	)
+}
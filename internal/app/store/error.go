@@ -5,4 +5,6 @@ import "errors"
 var (
 	// ErrRecordNotFound ...
 	ErrRecordNotFound = errors.New("record not found")
+	// ErrConflict is returned when a record with the same key exists.
+	ErrConflict = errors.New("record already exists")
 )
@@ -0,0 +1,161 @@
+package mongodbstore
+
+import (
+	"context"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository ...
+type AuditRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *AuditRepository) Create(e *model.AuditEvent) error {
+	res, err := r.store.db.Collection("audit_events").InsertOne(context.Background(), e)
+	if isDuplicateKey(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	e.ID = res.InsertedID.(primitive.ObjectID)
+
+	return nil
+}
+
+// Find ...
+func (r *AuditRepository) Find(f *model.AuditFilter) ([]*model.AuditEvent, error) {
+	filter := bson.M{}
+	if f.UserID != "" {
+		filter["$or"] = bson.A{
+			bson.M{"actor": f.UserID},
+			bson.M{"subject": f.UserID},
+		}
+	}
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		between := bson.M{}
+		if !f.From.IsZero() {
+			between["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			between["$lt"] = f.To
+		}
+		filter["time"] = between
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(f.Offset))
+	if f.Limit > 0 {
+		opts.SetLimit(int64(f.Limit))
+	}
+
+	ctx := context.Background()
+	cur, err := r.store.db.Collection("audit_events").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*model.AuditEvent
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Last ...
+func (r *AuditRepository) Last() (*model.AuditEvent, error) {
+	e := &model.AuditEvent{}
+	err := r.store.db.Collection("audit_events").FindOne(
+		context.Background(),
+		bson.M{},
+		options.FindOne().SetSort(bson.M{"seq": -1}),
+	).Decode(e)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Walk ...
+func (r *AuditRepository) Walk(fn func(*model.AuditEvent) error) error {
+	ctx := context.Background()
+	cur, err := r.store.db.Collection("audit_events").Find(
+		ctx,
+		bson.M{},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		e := &model.AuditEvent{}
+		if err := cur.Decode(e); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+// CreateCheckpoint ...
+func (r *AuditRepository) CreateCheckpoint(c *model.AuditCheckpoint) error {
+	_, err := r.store.db.Collection("audit_checkpoints").InsertOne(context.Background(), c)
+	if isDuplicateKey(err) {
+		return store.ErrConflict
+	}
+
+	return err
+}
+
+// FindCheckpoints ...
+func (r *AuditRepository) FindCheckpoints() ([]*model.AuditCheckpoint, error) {
+	ctx := context.Background()
+	cur, err := r.store.db.Collection("audit_checkpoints").Find(
+		ctx,
+		bson.M{},
+		options.Find().SetSort(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []*model.AuditCheckpoint
+	if err := cur.All(ctx, &checkpoints); err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+func isDuplicateKey(err error) bool {
+	if e, ok := err.(mongo.WriteException); ok {
+		for _, we := range e.WriteErrors {
+			if we.Code == 11000 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
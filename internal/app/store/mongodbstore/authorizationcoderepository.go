@@ -0,0 +1,36 @@
+package mongodbstore
+
+import (
+	"context"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuthorizationCodeRepository ...
+type AuthorizationCodeRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *AuthorizationCodeRepository) Create(c *model.AuthorizationCode) error {
+	_, err := r.store.db.Collection("authorization_codes").InsertOne(context.Background(), c)
+
+	return err
+}
+
+// Consume ...
+func (r *AuthorizationCodeRepository) Consume(codeHash string) (*model.AuthorizationCode, error) {
+	c := &model.AuthorizationCode{}
+	err := r.store.db.Collection("authorization_codes").FindOneAndDelete(context.Background(), bson.M{"_id": codeHash}).Decode(c)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
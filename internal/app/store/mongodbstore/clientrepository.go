@@ -0,0 +1,43 @@
+package mongodbstore
+
+import (
+	"context"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ClientRepository ...
+type ClientRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *ClientRepository) Create(c *model.Client) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.BeforeCreate(); err != nil {
+		return err
+	}
+
+	_, err := r.store.db.Collection("clients").InsertOne(context.Background(), c)
+
+	return err
+}
+
+// Find ...
+func (r *ClientRepository) Find(id string) (*model.Client, error) {
+	c := &model.Client{}
+	if err := r.store.db.Collection("clients").FindOne(context.Background(), bson.M{"_id": id}).Decode(c); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,57 @@
+package mongodbstore
+
+import (
+	"context"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OneTimeTokenRepository ...
+type OneTimeTokenRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *OneTimeTokenRepository) Create(t *model.OneTimeToken) error {
+	_, err := r.store.db.Collection("one_time_tokens").InsertOne(context.Background(), t)
+
+	return err
+}
+
+// Find ...
+func (r *OneTimeTokenRepository) Find(hash string, purpose string) (*model.OneTimeToken, error) {
+	t := &model.OneTimeToken{}
+	err := r.store.db.Collection("one_time_tokens").FindOne(context.Background(), bson.M{"_id": hash, "purpose": purpose}).Decode(t)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Consume ...
+func (r *OneTimeTokenRepository) Consume(hash string, purpose string) (*model.OneTimeToken, error) {
+	t := &model.OneTimeToken{}
+	err := r.store.db.Collection("one_time_tokens").FindOneAndDelete(context.Background(), bson.M{"_id": hash, "purpose": purpose}).Decode(t)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// DeleteAll ...
+func (r *OneTimeTokenRepository) DeleteAll(userID string, purpose string) error {
+	_, err := r.store.db.Collection("one_time_tokens").DeleteMany(context.Background(), bson.M{"userId": userID, "purpose": purpose})
+
+	return err
+}
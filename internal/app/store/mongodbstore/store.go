@@ -7,8 +7,15 @@ import (
 
 // Store ...
 type Store struct {
-	db              *mongo.Database
-	tokenRepository *TokenRepository
+	db                          *mongo.Database
+	userRepository              *UserRepository
+	tokenRepository             *TokenRepository
+	clientRepository            *ClientRepository
+	authorizationCodeRepository *AuthorizationCodeRepository
+	oneTimeTokenRepository      *OneTimeTokenRepository
+	auditRepository             *AuditRepository
+	webhookRepository           *WebhookRepository
+	webhookDeliveryRepository   *WebhookDeliveryRepository
 }
 
 // New ...
@@ -18,6 +25,19 @@ func New(db *mongo.Database) *Store {
 	}
 }
 
+// User ...
+func (s *Store) User() store.UserRepository {
+	if s.userRepository != nil {
+		return s.userRepository
+	}
+
+	s.userRepository = &UserRepository{
+		store: s,
+	}
+
+	return s.userRepository
+}
+
 // Token ...
 func (s *Store) Token() store.TokenRepository {
 	if s.tokenRepository != nil {
@@ -30,3 +50,81 @@ func (s *Store) Token() store.TokenRepository {
 
 	return s.tokenRepository
 }
+
+// Client ...
+func (s *Store) Client() store.ClientRepository {
+	if s.clientRepository != nil {
+		return s.clientRepository
+	}
+
+	s.clientRepository = &ClientRepository{
+		store: s,
+	}
+
+	return s.clientRepository
+}
+
+// AuthorizationCode ...
+func (s *Store) AuthorizationCode() store.AuthorizationCodeRepository {
+	if s.authorizationCodeRepository != nil {
+		return s.authorizationCodeRepository
+	}
+
+	s.authorizationCodeRepository = &AuthorizationCodeRepository{
+		store: s,
+	}
+
+	return s.authorizationCodeRepository
+}
+
+// OneTimeToken ...
+func (s *Store) OneTimeToken() store.OneTimeTokenRepository {
+	if s.oneTimeTokenRepository != nil {
+		return s.oneTimeTokenRepository
+	}
+
+	s.oneTimeTokenRepository = &OneTimeTokenRepository{
+		store: s,
+	}
+
+	return s.oneTimeTokenRepository
+}
+
+// Audit ...
+func (s *Store) Audit() store.AuditRepository {
+	if s.auditRepository != nil {
+		return s.auditRepository
+	}
+
+	s.auditRepository = &AuditRepository{
+		store: s,
+	}
+
+	return s.auditRepository
+}
+
+// Webhook ...
+func (s *Store) Webhook() store.WebhookRepository {
+	if s.webhookRepository != nil {
+		return s.webhookRepository
+	}
+
+	s.webhookRepository = &WebhookRepository{
+		store: s,
+	}
+
+	return s.webhookRepository
+}
+
+// WebhookDelivery ...
+func (s *Store) WebhookDelivery() store.WebhookDeliveryRepository {
+	if s.webhookDeliveryRepository != nil {
+		return s.webhookDeliveryRepository
+	}
+
+	s.webhookDeliveryRepository = &WebhookDeliveryRepository{
+		store: s,
+	}
+
+	return s.webhookDeliveryRepository
+}
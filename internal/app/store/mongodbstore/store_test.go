@@ -3,6 +3,10 @@ package mongodbstore_test
 import (
 	"os"
 	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/mongodbstore"
+	"github.com/psihachina/go-test-work.git/internal/app/store/storetest"
 )
 
 var (
@@ -18,3 +22,18 @@ func TestMain(m *testing.M) {
 
 	os.Exit(m.Run())
 }
+
+// TestStore needs a replica set, as the token repository uses transactions,
+// so it only runs when DATABASE_URL is set.
+func TestStore(t *testing.T) {
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		db, teardown := mongodbstore.TestDB(t, databaseUrl, "test_database")
+		t.Cleanup(func() { teardown("refresh_sessions", "users") })
+
+		return mongodbstore.New(db)
+	})
+}
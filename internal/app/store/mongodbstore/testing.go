@@ -19,7 +19,8 @@ func TestDB(t *testing.T, databaseUrl string, databaseName string) (*mongo.Datab
 		t.Fatal(err)
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	err = db.Connect(ctx)
 	if err != nil {
@@ -33,7 +34,7 @@ func TestDB(t *testing.T, databaseUrl string, databaseName string) (*mongo.Datab
 					t.Fatal(err)
 				}
 			}
-			db.Disconnect(ctx)
+			db.Disconnect(context.Background())
 		}
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/psihachina/go-test-work.git/internal/app/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type TokenRepository struct {
@@ -35,7 +36,8 @@ func (r *TokenRepository) CreateAuth(userid string, td *model.TokenDetails) erro
 		if resultRef, err = collection.InsertOne(sc, bson.M{
 			"refreshToken": td.RefreshUuid,
 			"userId":       userid,
-			"createAt":     rt.Sub(now)}); err != nil {
+			"createAt":     rt.Sub(now),
+			"expiresAt":    rt}); err != nil {
 			log.Fatal(err)
 		}
 		if err != nil {
@@ -61,7 +63,6 @@ func (r *TokenRepository) DeleteTokens(authD *model.AccessDetails) error {
 	var ctx = context.Background()
 	var err error
 	var session mongo.Session
-	var deletedRt *mongo.DeleteResult
 	var collection *mongo.Collection
 
 	if session, err = r.store.db.Client().StartSession(); err != nil {
@@ -72,7 +73,7 @@ func (r *TokenRepository) DeleteTokens(authD *model.AccessDetails) error {
 	}
 	if err = mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
 		collection = r.store.db.Collection("refresh_sessions")
-		deletedRt, err = collection.DeleteMany(context.Background(), bson.M{"userId": authD.UserID})
+		_, err = collection.DeleteMany(context.Background(), bson.M{"userId": authD.UserID})
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -124,3 +125,24 @@ func (r *TokenRepository) DeleteAuth(givenUuid string) (int64, error) {
 
 	return deletedRt.DeletedCount, nil
 }
+
+// FindByUser leaves out the expired sessions the TTL index has not
+// deleted yet.
+func (r *TokenRepository) FindByUser(userID string) ([]*model.Session, error) {
+	ctx := context.Background()
+	cur, err := r.store.db.Collection("refresh_sessions").Find(
+		ctx,
+		bson.M{"userId": userID, "expiresAt": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"expiresAt": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*model.Session
+	if err := cur.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,259 @@
+package mongodbstore
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserRepository ...
+type UserRepository struct {
+	store *Store
+}
+
+// userDocument is a user as stored. The lowercased username and email let
+// List match a prefix case-insensitively with an index.
+type userDocument struct {
+	model.User    `bson:",inline"`
+	UsernameLower string `bson:"username_lower"`
+	EmailLower    string `bson:"email_lower"`
+}
+
+func newUserDocument(u *model.User) *userDocument {
+	return &userDocument{
+		User:          *u,
+		UsernameLower: strings.ToLower(u.Username),
+		EmailLower:    strings.ToLower(u.Email),
+	}
+}
+
+// Create ...
+func (r *UserRepository) Create(u *model.User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	if err := u.BeforeCreate(); err != nil {
+		return err
+	}
+
+	res, err := r.store.db.Collection("users").InsertOne(context.Background(), newUserDocument(u))
+	if err != nil {
+		return err
+	}
+	u.ID = res.InsertedID.(primitive.ObjectID)
+
+	return nil
+}
+
+// Update ...
+func (r *UserRepository) Update(u *model.User) error {
+	res, err := r.store.db.Collection("users").ReplaceOne(context.Background(), bson.M{"_id": u.ID}, newUserDocument(u))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// UpdateStatus ...
+func (r *UserRepository) UpdateStatus(id string, status string) error {
+	return r.set(id, bson.M{"status": status})
+}
+
+// UpdatePassword ...
+func (r *UserRepository) UpdatePassword(id string, encryptedPassword string) error {
+	return r.set(id, bson.M{"password": encryptedPassword})
+}
+
+// MarkEmailVerified ...
+func (r *UserRepository) MarkEmailVerified(id string) error {
+	if err := r.set(id, bson.M{"email_verified": true}); err != nil {
+		return err
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(id)
+	_, err := r.store.db.Collection("users").UpdateOne(
+		context.Background(),
+		bson.M{"_id": oid, "status": model.UserStatusPendingVerification},
+		bson.M{"$set": bson.M{"status": model.UserStatusActive}},
+	)
+
+	return err
+}
+
+// UpdateTOTP ...
+func (r *UserRepository) UpdateTOTP(u *model.User) error {
+	return r.set(u.ID.Hex(), bson.M{
+		"totp_secret":    u.TOTPSecret,
+		"totp_enabled":   u.TOTPEnabled,
+		"totp_last_step": u.TOTPLastStep,
+		"recovery_codes": u.RecoveryCodes,
+	})
+}
+
+// UseTOTPStep ...
+func (r *UserRepository) UseTOTPStep(id string, step int64) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, store.ErrRecordNotFound
+	}
+
+	res, err := r.store.db.Collection("users").UpdateOne(
+		context.Background(),
+		bson.M{"_id": oid, "$or": bson.A{
+			bson.M{"totp_last_step": bson.M{"$exists": false}},
+			bson.M{"totp_last_step": bson.M{"$lt": step}},
+		}},
+		bson.M{"$set": bson.M{"totp_last_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+// UseRecoveryCode ...
+func (r *UserRepository) UseRecoveryCode(id string, codeHash string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, store.ErrRecordNotFound
+	}
+
+	res, err := r.store.db.Collection("users").UpdateOne(
+		context.Background(),
+		bson.M{"_id": oid, "recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"recovery_codes": codeHash}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+// AddMFAFailure ...
+func (r *UserRepository) AddMFAFailure(id string, at time.Time) (int, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, store.ErrRecordNotFound
+	}
+
+	u := &model.User{}
+	err = r.store.db.Collection("users").FindOneAndUpdate(
+		context.Background(),
+		bson.M{"_id": oid},
+		bson.M{"$inc": bson.M{"mfa_failures": 1}, "$set": bson.M{"mfa_last_failure": at}},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"mfa_failures": 1}),
+	).Decode(u)
+	if err == mongo.ErrNoDocuments {
+		return 0, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return u.MFAFailures, nil
+}
+
+// ResetMFAFailures ...
+func (r *UserRepository) ResetMFAFailures(id string) error {
+	return r.set(id, bson.M{"mfa_failures": 0})
+}
+
+// Find ...
+func (r *UserRepository) Find(id string) (*model.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return r.findOne(bson.M{"_id": oid})
+}
+
+// FindByEmail ...
+func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
+	return r.findOne(bson.M{"email": email})
+}
+
+// List pages through the users in the order of the
+// username_sort_by_asc_created index. The query is matched as a prefix of
+// the lowercased username and email, which users_username_lower and
+// users_email_lower index.
+func (r *UserRepository) List(f *model.UserFilter) ([]*model.User, error) {
+	filter := bson.M{}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	if f.Query != "" {
+		prefix := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(f.Query))}
+		filter["$or"] = bson.A{
+			bson.M{"username_lower": prefix},
+			bson.M{"email_lower": prefix},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "username", Value: 1}, {Key: "created", Value: -1}}).
+		SetSkip(int64(f.Offset))
+	if f.Limit > 0 {
+		opts.SetLimit(int64(f.Limit))
+	}
+
+	ctx := context.Background()
+	cur, err := r.store.db.Collection("users").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*model.User
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// set updates the given fields of the user.
+func (r *UserRepository) set(id string, fields bson.M) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return store.ErrRecordNotFound
+	}
+
+	res, err := r.store.db.Collection("users").UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *UserRepository) findOne(filter bson.M) (*model.User, error) {
+	u := &model.User{}
+	if err := r.store.db.Collection("users").FindOne(context.Background(), filter).Decode(u); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
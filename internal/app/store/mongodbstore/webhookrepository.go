@@ -0,0 +1,176 @@
+package mongodbstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WebhookRepository ...
+type WebhookRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *WebhookRepository) Create(w *model.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	if w.Created.IsZero() {
+		w.Created = time.Now()
+	}
+
+	res, err := r.store.db.Collection("webhooks").InsertOne(context.Background(), w)
+	if err != nil {
+		return err
+	}
+	w.ID = res.InsertedID.(primitive.ObjectID)
+
+	return nil
+}
+
+// Find ...
+func (r *WebhookRepository) Find(id string) (*model.Webhook, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	w := &model.Webhook{}
+	if err := r.store.db.Collection("webhooks").FindOne(context.Background(), bson.M{"_id": oid}).Decode(w); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, store.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// List ...
+func (r *WebhookRepository) List() ([]*model.Webhook, error) {
+	return r.find(bson.M{})
+}
+
+// FindByEvent ...
+func (r *WebhookRepository) FindByEvent(event string) ([]*model.Webhook, error) {
+	if event == model.WebhookPing {
+		return r.find(bson.M{})
+	}
+	return r.find(bson.M{"events": event})
+}
+
+// Delete ...
+func (r *WebhookRepository) Delete(id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return store.ErrRecordNotFound
+	}
+
+	res, err := r.store.db.Collection("webhooks").DeleteOne(context.Background(), bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return store.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) find(filter bson.M) ([]*model.Webhook, error) {
+	ctx := context.Background()
+	cur, err := r.store.db.Collection("webhooks").Find(ctx, filter, options.Find().SetSort(bson.M{"created": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var webhooks []*model.Webhook
+	if err := cur.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// WebhookDeliveryRepository ...
+type WebhookDeliveryRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *WebhookDeliveryRepository) Create(d *model.WebhookDelivery) error {
+	if d.ID.IsZero() {
+		d.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.store.db.Collection("webhook_deliveries").InsertOne(context.Background(), d)
+
+	return err
+}
+
+// Claim ...
+func (r *WebhookDeliveryRepository) Claim(now time.Time, lease time.Duration) (*model.WebhookDelivery, error) {
+	d := &model.WebhookDelivery{}
+	err := r.store.db.Collection("webhook_deliveries").FindOneAndUpdate(
+		context.Background(),
+		bson.M{"status": model.DeliveryPending, "nextAttempt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"nextAttempt": now.Add(lease)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.M{"nextAttempt": 1}).
+			SetReturnDocument(options.After),
+	).Decode(d)
+	if err == mongo.ErrNoDocuments {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Update ...
+func (r *WebhookDeliveryRepository) Update(d *model.WebhookDelivery) error {
+	res, err := r.store.db.Collection("webhook_deliveries").ReplaceOne(context.Background(), bson.M{"_id": d.ID}, d)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// FindByWebhook ...
+func (r *WebhookDeliveryRepository) FindByWebhook(webhookID string, status string) ([]*model.WebhookDelivery, error) {
+	oid, err := primitive.ObjectIDFromHex(webhookID)
+	if err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	ctx := context.Background()
+	cur, err := r.store.db.Collection("webhook_deliveries").Find(
+		ctx,
+		bson.M{"webhookId": oid, "status": status},
+		options.Find().SetSort(bson.M{"created": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []*model.WebhookDelivery
+	if err := cur.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
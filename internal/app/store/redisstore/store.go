@@ -0,0 +1,35 @@
+package redisstore
+
+import (
+	"github.com/go-redis/redis/v7"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// Store keeps refresh sessions in Redis and leaves the other repositories
+// to the store it wraps.
+type Store struct {
+	store.Store
+	client          *redis.Client
+	tokenRepository *TokenRepository
+}
+
+// New ...
+func New(client *redis.Client, base store.Store) *Store {
+	return &Store{
+		Store:  base,
+		client: client,
+	}
+}
+
+// Token ...
+func (s *Store) Token() store.TokenRepository {
+	if s.tokenRepository != nil {
+		return s.tokenRepository
+	}
+
+	s.tokenRepository = &TokenRepository{
+		store: s,
+	}
+
+	return s.tokenRepository
+}
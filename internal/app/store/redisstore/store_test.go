@@ -0,0 +1,15 @@
+package redisstore_test
+
+import (
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, _ := testStore(t)
+		return s
+	})
+}
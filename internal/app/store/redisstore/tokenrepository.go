@@ -0,0 +1,129 @@
+package redisstore
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// A session is a hash under sessionKey that expires with its refresh
+// token. The refresh UUIDs of a user are kept in a set under userKey so
+// that all sessions of the user can be deleted; the set lives as long as
+// the user's last session and may briefly list expired sessions.
+const (
+	sessionPrefix = "refresh_session:"
+	userPrefix    = "user_sessions:"
+)
+
+func sessionKey(refreshUUID string) string {
+	return sessionPrefix + refreshUUID
+}
+
+func userKey(userID string) string {
+	return userPrefix + userID
+}
+
+// createAuth stores the session and adds it to the user's set, extending
+// the set's expiry when the new session outlives it.
+var createAuth = redis.NewScript(`
+redis.call("HSET", KEYS[1], "userId", ARGV[1], "expiresAt", ARGV[2])
+redis.call("EXPIREAT", KEYS[1], ARGV[2])
+redis.call("SADD", KEYS[2], ARGV[3])
+local ttl = redis.call("TTL", KEYS[2])
+if ttl < 0 or ttl < tonumber(ARGV[2]) - tonumber(ARGV[4]) then
+	redis.call("EXPIREAT", KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+// deleteAuth deletes the session and removes it from the user's set. Only
+// one of concurrent calls for a session sees it deleted.
+var deleteAuth = redis.NewScript(`
+local userId = redis.call("HGET", KEYS[1], "userId")
+if not userId then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+redis.call("SREM", ARGV[1] .. userId, ARGV[2])
+return 1
+`)
+
+// deleteTokens deletes every session of the user and the user's set.
+var deleteTokens = redis.NewScript(`
+local uuids = redis.call("SMEMBERS", KEYS[1])
+for _, uuid in ipairs(uuids) do
+	redis.call("DEL", ARGV[1] .. uuid)
+end
+redis.call("DEL", KEYS[1])
+return #uuids
+`)
+
+// TokenRepository ...
+type TokenRepository struct {
+	store *Store
+}
+
+// CreateAuth ...
+func (r *TokenRepository) CreateAuth(userid string, td *model.TokenDetails) error {
+	return createAuth.Run(
+		r.store.client,
+		[]string{sessionKey(td.RefreshUuid), userKey(userid)},
+		userid, td.RtExpires, td.RefreshUuid, time.Now().Unix(),
+	).Err()
+}
+
+// DeleteTokens ...
+func (r *TokenRepository) DeleteTokens(authD *model.AccessDetails) error {
+	return deleteTokens.Run(
+		r.store.client,
+		[]string{userKey(authD.UserID)},
+		sessionPrefix,
+	).Err()
+}
+
+// DeleteAuth ...
+func (r *TokenRepository) DeleteAuth(givenUuid string) (int64, error) {
+	return deleteAuth.Run(
+		r.store.client,
+		[]string{sessionKey(givenUuid)},
+		userPrefix, givenUuid,
+	).Int64()
+}
+
+// FindByUser ...
+func (r *TokenRepository) FindByUser(userID string) ([]*model.Session, error) {
+	uuids, err := r.store.client.SMembers(userKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*model.Session
+	for _, uuid := range uuids {
+		expiresAt, err := r.store.client.HGet(sessionKey(uuid), "expiresAt").Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		unix, err := strconv.ParseInt(expiresAt, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &model.Session{
+			RefreshUUID: uuid,
+			UserID:      userID,
+			ExpiresAt:   time.Unix(unix, 0),
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ExpiresAt.Before(sessions[j].ExpiresAt)
+	})
+
+	return sessions, nil
+}
@@ -0,0 +1,136 @@
+package redisstore_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/redisstore"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T) (*redisstore.Store, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return redisstore.New(client, teststore.New()), mr
+}
+
+func testTokenDetails(refreshUUID string, expires time.Time) *model.TokenDetails {
+	return &model.TokenDetails{
+		RefreshUuid: refreshUUID,
+		RtExpires:   expires.Unix(),
+	}
+}
+
+func TestTokenRepository_CreateAuth(t *testing.T) {
+	s, mr := testStore(t)
+	now := time.Now()
+	mr.SetTime(now)
+
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("b", now.Add(2*time.Hour))))
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("a", now.Add(time.Hour))))
+
+	sessions, err := s.Token().FindByUser("user")
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, "a", sessions[0].RefreshUUID)
+		assert.Equal(t, "user", sessions[0].UserID)
+		assert.Equal(t, now.Add(time.Hour).Unix(), sessions[0].ExpiresAt.Unix())
+		assert.Equal(t, "b", sessions[1].RefreshUUID)
+	}
+	assert.Equal(t, 2*time.Hour, mr.TTL("user_sessions:user").Round(time.Minute))
+}
+
+func TestTokenRepository_Expiry(t *testing.T) {
+	s, mr := testStore(t)
+	now := time.Now()
+	mr.SetTime(now)
+
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("a", now.Add(time.Hour))))
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("b", now.Add(2*time.Hour))))
+
+	mr.FastForward(time.Hour + time.Second)
+	sessions, _ := s.Token().FindByUser("user")
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, "b", sessions[0].RefreshUUID)
+	}
+	deleted, err := s.Token().DeleteAuth("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	mr.FastForward(time.Hour)
+	assert.False(t, mr.Exists("refresh_session:b"))
+	assert.False(t, mr.Exists("user_sessions:user"))
+}
+
+func TestTokenRepository_DeleteAuth(t *testing.T) {
+	s, mr := testStore(t)
+	expires := time.Now().Add(time.Hour)
+
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("a", expires)))
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("b", expires)))
+
+	deleted, err := s.Token().DeleteAuth("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = s.Token().DeleteAuth("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	members, _ := mr.Members("user_sessions:user")
+	assert.Equal(t, []string{"b"}, members)
+}
+
+func TestTokenRepository_DeleteAuth_Concurrent(t *testing.T) {
+	s, _ := testStore(t)
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("a", time.Now().Add(time.Hour))))
+
+	var wg sync.WaitGroup
+	var total int64
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deleted, err := s.Token().DeleteAuth("a")
+			assert.NoError(t, err)
+			atomic.AddInt64(&total, deleted)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), total)
+}
+
+func TestTokenRepository_DeleteTokens(t *testing.T) {
+	s, mr := testStore(t)
+	expires := time.Now().Add(time.Hour)
+
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("a", expires)))
+	assert.NoError(t, s.Token().CreateAuth("user", testTokenDetails("b", expires)))
+	assert.NoError(t, s.Token().CreateAuth("other", testTokenDetails("c", expires)))
+
+	assert.NoError(t, s.Token().DeleteTokens(&model.AccessDetails{UserID: "user"}))
+
+	sessions, _ := s.Token().FindByUser("user")
+	assert.Empty(t, sessions)
+	assert.False(t, mr.Exists("refresh_session:a"))
+	assert.False(t, mr.Exists("user_sessions:user"))
+
+	sessions, _ = s.Token().FindByUser("other")
+	assert.Len(t, sessions, 1)
+}
@@ -1,9 +1,115 @@
 package store
 
-import "github.com/psihachina/go-test-work.git/internal/app/model"
+import (
+	"time"
 
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// UserRepository ...
+type UserRepository interface {
+	Create(*model.User) error
+	Update(*model.User) error
+	// UpdateStatus, UpdatePassword, MarkEmailVerified and UpdateTOTP write
+	// only the fields they name, so that they do not undo a concurrent
+	// change to the rest of the user. They return ErrRecordNotFound when
+	// the user does not exist.
+	UpdateStatus(string, string) error
+	UpdatePassword(string, string) error
+	// MarkEmailVerified also activates a user pending verification.
+	MarkEmailVerified(string) error
+	// UpdateTOTP writes the TOTP secret, state and recovery codes of the
+	// user.
+	UpdateTOTP(*model.User) error
+	Find(string) (*model.User, error)
+	FindByEmail(string) (*model.User, error)
+	// List returns the users matching the filter, ordered by username and
+	// then newest first.
+	List(*model.UserFilter) ([]*model.User, error)
+	// UseTOTPStep records the TOTP step as used by the user and reports
+	// false if that step or a later one was already used.
+	UseTOTPStep(string, int64) (bool, error)
+	// UseRecoveryCode removes the recovery code hash from the user and
+	// reports false if the user did not have it.
+	UseRecoveryCode(string, string) (bool, error)
+	// AddMFAFailure counts a failed second-factor code of the user at the
+	// given time and returns the number of failures in a row.
+	AddMFAFailure(string, time.Time) (int, error)
+	// ResetMFAFailures clears the failure count of the user.
+	ResetMFAFailures(string) error
+}
+
+// TokenRepository ...
 type TokenRepository interface {
 	CreateAuth(string, *model.TokenDetails) error
 	DeleteTokens(*model.AccessDetails) error
 	DeleteAuth(string) (int64, error)
+	// FindByUser returns the refresh sessions of the user.
+	FindByUser(string) ([]*model.Session, error)
+}
+
+// ClientRepository ...
+type ClientRepository interface {
+	Create(*model.Client) error
+	Find(string) (*model.Client, error)
+}
+
+// AuthorizationCodeRepository ...
+type AuthorizationCodeRepository interface {
+	Create(*model.AuthorizationCode) error
+	// Consume deletes the code with the given hash and returns it, so that
+	// a code can be exchanged only once.
+	Consume(string) (*model.AuthorizationCode, error)
+}
+
+// OneTimeTokenRepository ...
+type OneTimeTokenRepository interface {
+	Create(*model.OneTimeToken) error
+	// Find returns the token with the given hash and purpose without using
+	// it up.
+	Find(string, string) (*model.OneTimeToken, error)
+	// Consume deletes the token with the given hash and purpose and returns
+	// it, so that a token can be used only once.
+	Consume(string, string) (*model.OneTimeToken, error)
+	// DeleteAll deletes the tokens of the user with the given purpose.
+	DeleteAll(string, string) error
+}
+
+// AuditRepository is append-only: events are never updated or deleted.
+type AuditRepository interface {
+	// Create returns ErrConflict if an event with the same Seq exists.
+	Create(*model.AuditEvent) error
+	// Find returns the events matching the filter, newest first.
+	Find(*model.AuditFilter) ([]*model.AuditEvent, error)
+	// Last returns the event with the highest Seq.
+	Last() (*model.AuditEvent, error)
+	// Walk calls fn with every event in Seq order and stops at the first
+	// error, which it returns.
+	Walk(fn func(*model.AuditEvent) error) error
+	CreateCheckpoint(*model.AuditCheckpoint) error
+	// FindCheckpoints returns the checkpoints in Seq order.
+	FindCheckpoints() ([]*model.AuditCheckpoint, error)
+}
+
+// WebhookRepository ...
+type WebhookRepository interface {
+	Create(*model.Webhook) error
+	Find(string) (*model.Webhook, error)
+	List() ([]*model.Webhook, error)
+	// FindByEvent returns the webhooks subscribed to the event.
+	FindByEvent(string) ([]*model.Webhook, error)
+	Delete(string) error
+}
+
+// WebhookDeliveryRepository is the durable queue of webhook deliveries.
+type WebhookDeliveryRepository interface {
+	Create(*model.WebhookDelivery) error
+	// Claim returns a pending delivery due at now and postpones its next
+	// attempt by lease, so that no other worker takes it meanwhile. It
+	// returns ErrRecordNotFound when nothing is due.
+	Claim(now time.Time, lease time.Duration) (*model.WebhookDelivery, error)
+	Update(*model.WebhookDelivery) error
+	// FindByWebhook returns the deliveries of the webhook with the given
+	// status, oldest first.
+	FindByWebhook(string, string) ([]*model.WebhookDelivery, error)
 }
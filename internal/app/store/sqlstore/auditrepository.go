@@ -0,0 +1,175 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const auditEventColumns = `id, type, outcome, actor, subject, reason, ip, user_agent, session_id,
+	time, seq, prev_hash, hash`
+
+// AuditRepository ...
+type AuditRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *AuditRepository) Create(e *model.AuditEvent) error {
+	id := primitive.NewObjectID()
+	_, err := r.store.db.Exec(
+		`INSERT INTO audit_events (`+auditEventColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		objectID(id),
+		e.Type,
+		e.Outcome,
+		e.Actor,
+		e.Subject,
+		e.Reason,
+		e.IP,
+		e.UserAgent,
+		e.SessionID,
+		e.Time.UTC(),
+		e.Seq,
+		e.PrevHash,
+		e.Hash,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	e.ID = id
+
+	return nil
+}
+
+// Find ...
+func (r *AuditRepository) Find(f *model.AuditFilter) ([]*model.AuditEvent, error) {
+	q := &query{}
+	var where []string
+	if f.UserID != "" {
+		userID := q.arg(f.UserID)
+		where = append(where, "(actor = "+userID+" OR subject = "+userID+")")
+	}
+	if f.Type != "" {
+		where = append(where, "type = "+q.arg(f.Type))
+	}
+	if !f.From.IsZero() {
+		where = append(where, "time >= "+q.arg(f.From.UTC()))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "time < "+q.arg(f.To.UTC()))
+	}
+
+	sqlQuery := `SELECT ` + auditEventColumns + ` FROM audit_events`
+	if len(where) > 0 {
+		sqlQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	sqlQuery += " ORDER BY time DESC, id DESC" + q.page(f.Offset, f.Limit)
+
+	var events []*model.AuditEvent
+	err := r.query(sqlQuery, q.args, func(e *model.AuditEvent) error {
+		events = append(events, e)
+		return nil
+	})
+
+	return events, err
+}
+
+// Last ...
+func (r *AuditRepository) Last() (*model.AuditEvent, error) {
+	e, err := scanAuditEvent(r.store.db.QueryRow(
+		`SELECT ` + auditEventColumns + ` FROM audit_events ORDER BY seq DESC, id DESC LIMIT 1`,
+	))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return e, err
+}
+
+// Walk ...
+func (r *AuditRepository) Walk(fn func(*model.AuditEvent) error) error {
+	return r.query(`SELECT `+auditEventColumns+` FROM audit_events ORDER BY seq, id`, nil, fn)
+}
+
+// CreateCheckpoint ...
+func (r *AuditRepository) CreateCheckpoint(c *model.AuditCheckpoint) error {
+	_, err := r.store.db.Exec(
+		`INSERT INTO audit_checkpoints (seq, hash, time, token) VALUES ($1, $2, $3, $4)`,
+		c.Seq, c.Hash, c.Time.UTC(), c.Token,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+
+	return err
+}
+
+// FindCheckpoints ...
+func (r *AuditRepository) FindCheckpoints() ([]*model.AuditCheckpoint, error) {
+	rows, err := r.store.db.Query(`SELECT seq, hash, time, token FROM audit_checkpoints ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []*model.AuditCheckpoint
+	for rows.Next() {
+		c := &model.AuditCheckpoint{}
+		if err := rows.Scan(&c.Seq, &c.Hash, (*timestamp)(&c.Time), &c.Token); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, c)
+	}
+
+	return checkpoints, rows.Err()
+}
+
+func (r *AuditRepository) query(sqlQuery string, args []interface{}, fn func(*model.AuditEvent) error) error {
+	rows, err := r.store.db.Query(sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func scanAuditEvent(row scanner) (*model.AuditEvent, error) {
+	e := &model.AuditEvent{}
+	if err := row.Scan(
+		(*objectID)(&e.ID),
+		&e.Type,
+		&e.Outcome,
+		&e.Actor,
+		&e.Subject,
+		&e.Reason,
+		&e.IP,
+		&e.UserAgent,
+		&e.SessionID,
+		(*timestamp)(&e.Time),
+		&e.Seq,
+		&e.PrevHash,
+		&e.Hash,
+	); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,58 @@
+package sqlstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditRepository(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("audit_events", "audit_checkpoints")
+
+	s := sqlstore.New(db)
+	_, err := s.Audit().Last()
+	assert.Equal(t, store.ErrRecordNotFound, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	events := []*model.AuditEvent{
+		{Type: model.AuditLogin, Outcome: model.AuditSuccess, Subject: "user", Time: now.Add(-2 * time.Minute), Seq: 1},
+		{Type: model.AuditLogout, Outcome: model.AuditSuccess, Actor: "user", Time: now.Add(-time.Minute), Seq: 2},
+		{Type: model.AuditLogin, Outcome: model.AuditFailure, Subject: "other", Time: now, Seq: 3},
+	}
+	for _, e := range events {
+		e.Hash = e.ComputeHash()
+		assert.NoError(t, s.Audit().Create(e))
+	}
+	assert.Equal(t, store.ErrConflict, s.Audit().Create(&model.AuditEvent{Type: model.AuditLogin, Time: now, Seq: 3}))
+
+	last, err := s.Audit().Last()
+	assert.NoError(t, err)
+	assert.Equal(t, events[2].ID, last.ID)
+	assert.Equal(t, events[2].Hash, last.ComputeHash())
+
+	found, err := s.Audit().Find(&model.AuditFilter{UserID: "user"})
+	assert.NoError(t, err)
+	if assert.Len(t, found, 2) {
+		assert.Equal(t, model.AuditLogout, found[0].Type)
+	}
+	found, _ = s.Audit().Find(&model.AuditFilter{From: now.Add(-time.Minute), To: now})
+	assert.Len(t, found, 1)
+
+	var seqs []int64
+	assert.NoError(t, s.Audit().Walk(func(e *model.AuditEvent) error {
+		seqs = append(seqs, e.Seq)
+		return nil
+	}))
+	assert.Equal(t, []int64{1, 2, 3}, seqs)
+
+	assert.NoError(t, s.Audit().CreateCheckpoint(&model.AuditCheckpoint{Seq: 3, Hash: last.Hash, Time: now, Token: "token"}))
+	assert.Equal(t, store.ErrConflict, s.Audit().CreateCheckpoint(&model.AuditCheckpoint{Seq: 3, Time: now}))
+	checkpoints, err := s.Audit().FindCheckpoints()
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+}
@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+const authorizationCodeColumns = `code_hash, client_id, user_id, redirect_uri, scopes,
+	code_challenge, code_challenge_method, nonce, auth_time, expires_at`
+
+// AuthorizationCodeRepository ...
+type AuthorizationCodeRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *AuthorizationCodeRepository) Create(c *model.AuthorizationCode) error {
+	_, err := r.store.db.Exec(
+		`INSERT INTO authorization_codes (`+authorizationCodeColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		c.CodeHash,
+		c.ClientID,
+		c.UserID,
+		c.RedirectURI,
+		stringList(c.Scopes),
+		c.CodeChallenge,
+		c.CodeChallengeMethod,
+		c.Nonce,
+		c.AuthTime,
+		c.ExpiresAt.UTC(),
+	)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+
+	return err
+}
+
+// Consume ...
+func (r *AuthorizationCodeRepository) Consume(codeHash string) (*model.AuthorizationCode, error) {
+	c := &model.AuthorizationCode{}
+	err := r.store.db.QueryRow(
+		`DELETE FROM authorization_codes WHERE code_hash = $1 RETURNING `+authorizationCodeColumns,
+		codeHash,
+	).Scan(
+		&c.CodeHash,
+		&c.ClientID,
+		&c.UserID,
+		&c.RedirectURI,
+		(*stringList)(&c.Scopes),
+		&c.CodeChallenge,
+		&c.CodeChallengeMethod,
+		&c.Nonce,
+		&c.AuthTime,
+		(*timestamp)(&c.ExpiresAt),
+	)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,60 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// ClientRepository ...
+type ClientRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *ClientRepository) Create(c *model.Client) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.BeforeCreate(); err != nil {
+		return err
+	}
+
+	_, err := r.store.db.Exec(
+		`INSERT INTO clients (id, name, secret_hash, redirect_uris, allowed_scopes, grant_types, token_ttl)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.ID, c.Name, c.SecretHash, stringList(c.RedirectURIs), stringList(c.AllowedScopes), stringList(c.GrantTypes), c.TokenTTL,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+
+	return err
+}
+
+// Find ...
+func (r *ClientRepository) Find(id string) (*model.Client, error) {
+	c := &model.Client{}
+	err := r.store.db.QueryRow(
+		`SELECT id, name, secret_hash, redirect_uris, allowed_scopes, grant_types, token_ttl FROM clients WHERE id = $1`,
+		id,
+	).Scan(
+		&c.ID,
+		&c.Name,
+		&c.SecretHash,
+		(*stringList)(&c.RedirectURIs),
+		(*stringList)(&c.AllowedScopes),
+		(*stringList)(&c.GrantTypes),
+		&c.TokenTTL,
+	)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,229 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"time"
+)
+
+// migration is a step of the schema. Versions follow the naming of the
+// Mongo migrations and are recorded in schema_migrations once applied.
+// The statements are plain SQL understood by PostgreSQL and SQLite.
+type migration struct {
+	version    int64
+	name       string
+	statements []string
+}
+
+var migrations = []migration{
+	{
+		version: 20201019130000,
+		name:    "create_users",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				id TEXT PRIMARY KEY,
+				email TEXT NOT NULL,
+				email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+				password TEXT NOT NULL DEFAULT '',
+				username TEXT NOT NULL DEFAULT '',
+				firstname TEXT NOT NULL DEFAULT '',
+				lastname TEXT NOT NULL DEFAULT '',
+				fullname TEXT NOT NULL DEFAULT '',
+				roles TEXT NOT NULL DEFAULT '[]',
+				scopes TEXT NOT NULL DEFAULT '[]',
+				totp_secret TEXT NOT NULL DEFAULT '',
+				totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+				totp_last_step BIGINT NOT NULL DEFAULT 0,
+				recovery_codes TEXT NOT NULL DEFAULT '[]',
+				status TEXT NOT NULL DEFAULT '',
+				created TIMESTAMP NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users (email)`,
+			`CREATE INDEX IF NOT EXISTS users_username_created ON users (username, created DESC)`,
+		},
+	},
+	{
+		version: 20201019130100,
+		name:    "create_refresh_sessions",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS refresh_sessions (
+				refresh_token TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS refresh_sessions_user_id ON refresh_sessions (user_id)`,
+			`CREATE INDEX IF NOT EXISTS refresh_sessions_expires_at ON refresh_sessions (expires_at)`,
+		},
+	},
+	{
+		version: 20201019130200,
+		name:    "create_oauth",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS clients (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				secret_hash TEXT NOT NULL DEFAULT '',
+				redirect_uris TEXT NOT NULL DEFAULT '[]',
+				allowed_scopes TEXT NOT NULL DEFAULT '[]',
+				grant_types TEXT NOT NULL DEFAULT '[]',
+				token_ttl BIGINT NOT NULL DEFAULT 0
+			)`,
+			`CREATE TABLE IF NOT EXISTS authorization_codes (
+				code_hash TEXT PRIMARY KEY,
+				client_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				redirect_uri TEXT NOT NULL,
+				scopes TEXT NOT NULL DEFAULT '[]',
+				code_challenge TEXT NOT NULL DEFAULT '',
+				code_challenge_method TEXT NOT NULL DEFAULT '',
+				nonce TEXT NOT NULL DEFAULT '',
+				auth_time BIGINT NOT NULL DEFAULT 0,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS authorization_codes_expires_at ON authorization_codes (expires_at)`,
+		},
+	},
+	{
+		version: 20201019130300,
+		name:    "create_one_time_tokens",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS one_time_tokens (
+				hash TEXT PRIMARY KEY,
+				purpose TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				client_id TEXT NOT NULL DEFAULT '',
+				expires_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS one_time_tokens_user_purpose ON one_time_tokens (user_id, purpose)`,
+			`CREATE INDEX IF NOT EXISTS one_time_tokens_expires_at ON one_time_tokens (expires_at)`,
+		},
+	},
+	{
+		version: 20201019130400,
+		name:    "create_audit_events",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS audit_events (
+				id TEXT PRIMARY KEY,
+				type TEXT NOT NULL,
+				outcome TEXT NOT NULL,
+				actor TEXT NOT NULL DEFAULT '',
+				subject TEXT NOT NULL DEFAULT '',
+				reason TEXT NOT NULL DEFAULT '',
+				ip TEXT NOT NULL DEFAULT '',
+				user_agent TEXT NOT NULL DEFAULT '',
+				session_id TEXT NOT NULL DEFAULT '',
+				time TIMESTAMP NOT NULL,
+				seq BIGINT NOT NULL DEFAULT 0,
+				prev_hash TEXT NOT NULL DEFAULT '',
+				hash TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS audit_events_seq ON audit_events (seq) WHERE seq > 0`,
+			`CREATE INDEX IF NOT EXISTS audit_events_by_subject ON audit_events (subject, time)`,
+			`CREATE INDEX IF NOT EXISTS audit_events_by_actor ON audit_events (actor, time)`,
+			`CREATE INDEX IF NOT EXISTS audit_events_by_type ON audit_events (type, time)`,
+			`CREATE INDEX IF NOT EXISTS audit_events_by_time ON audit_events (time)`,
+			`CREATE TABLE IF NOT EXISTS audit_checkpoints (
+				seq BIGINT PRIMARY KEY,
+				hash TEXT NOT NULL,
+				time TIMESTAMP NOT NULL,
+				token TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 20201019130500,
+		name:    "create_webhooks",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS webhooks (
+				id TEXT PRIMARY KEY,
+				url TEXT NOT NULL,
+				events TEXT NOT NULL DEFAULT '[]',
+				secret TEXT NOT NULL,
+				created TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+				id TEXT PRIMARY KEY,
+				webhook_id TEXT NOT NULL,
+				event TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt TIMESTAMP NOT NULL,
+				last_error TEXT NOT NULL DEFAULT '',
+				created TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (status, next_attempt)`,
+			`CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id, status)`,
+		},
+	},
+	{
+		version: 20201019130600,
+		name:    "add_user_mfa_failures",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN mfa_failures INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE users ADD COLUMN mfa_last_failure TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+		},
+	},
+}
+
+// Migrate applies the migrations db has not seen yet, each in its own
+// transaction.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied TIMESTAMP NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	applied := map[int64]bool{}
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := m.apply(db); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *migration) apply(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name, applied) VALUES ($1, $2, $3)`,
+		m.version, m.name, time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,69 @@
+package sqlstore
+
+import (
+	"database/sql"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// OneTimeTokenRepository ...
+type OneTimeTokenRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *OneTimeTokenRepository) Create(t *model.OneTimeToken) error {
+	_, err := r.store.db.Exec(
+		`INSERT INTO one_time_tokens (hash, purpose, user_id, client_id, expires_at) VALUES ($1, $2, $3, $4, $5)`,
+		t.Hash, t.Purpose, t.UserID, t.ClientID, t.ExpiresAt.UTC(),
+	)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+
+	return err
+}
+
+// Find ...
+func (r *OneTimeTokenRepository) Find(hash string, purpose string) (*model.OneTimeToken, error) {
+	t := &model.OneTimeToken{}
+	err := r.store.db.QueryRow(
+		`SELECT hash, purpose, user_id, client_id, expires_at FROM one_time_tokens
+		WHERE hash = $1 AND purpose = $2`,
+		hash, purpose,
+	).Scan(&t.Hash, &t.Purpose, &t.UserID, &t.ClientID, (*timestamp)(&t.ExpiresAt))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Consume ...
+func (r *OneTimeTokenRepository) Consume(hash string, purpose string) (*model.OneTimeToken, error) {
+	t := &model.OneTimeToken{}
+	err := r.store.db.QueryRow(
+		`DELETE FROM one_time_tokens WHERE hash = $1 AND purpose = $2
+		RETURNING hash, purpose, user_id, client_id, expires_at`,
+		hash, purpose,
+	).Scan(&t.Hash, &t.Purpose, &t.UserID, &t.ClientID, (*timestamp)(&t.ExpiresAt))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// DeleteAll ...
+func (r *OneTimeTokenRepository) DeleteAll(userID string, purpose string) error {
+	_, err := r.store.db.Exec(`DELETE FROM one_time_tokens WHERE user_id = $1 AND purpose = $2`, userID, purpose)
+
+	return err
+}
@@ -0,0 +1,68 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+)
+
+// Open connects to the database at databaseURL and brings its schema up to
+// date. A postgres:// or postgresql:// URL is a PostgreSQL server; a
+// file:// URL is an SQLite database in that file, created if missing,
+// which needs no server at all.
+func Open(databaseURL string) (*sql.DB, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var db *sql.DB
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		db, err = sql.Open("postgres", databaseURL)
+	case "file":
+		db, err = openFile(u)
+	default:
+		return nil, fmt.Errorf("unsupported database url scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// openFile opens the SQLite database of a file URL: file:///abs/path.db,
+// file://rel/path.db or file:rel/path.db. Writes are serialized through a
+// single connection, so that concurrent requests never see SQLITE_BUSY.
+func openFile(u *url.URL) (*sql.DB, error) {
+	path := u.Opaque
+	if path == "" {
+		path = u.Host + u.Path
+	}
+	if path == "" {
+		return nil, fmt.Errorf("database url has no path: %s", u)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,45 @@
+package sqlstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	databaseURL := "file://" + filepath.Join(dir, "apiserver.db")
+	db, err := sqlstore.Open(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := model.TestUser(t)
+	assert.NoError(t, sqlstore.New(db).User().Create(u))
+	db.Close()
+
+	// Reopening keeps the data and does not apply the migrations again.
+	db, err = sqlstore.Open(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = sqlstore.New(db).User().Find(u.ID.Hex())
+	assert.NoError(t, err)
+}
+
+func TestOpen_UnsupportedScheme(t *testing.T) {
+	for _, databaseURL := range []string{"mysql://localhost/db", "file://"} {
+		_, err := sqlstore.Open(databaseURL)
+		assert.Error(t, err, databaseURL)
+	}
+}
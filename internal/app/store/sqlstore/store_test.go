@@ -0,0 +1,31 @@
+package sqlstore_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/psihachina/go-test-work.git/internal/app/store/storetest"
+)
+
+var (
+	databaseURL string
+)
+
+// TestMain runs the tests against the PostgreSQL database in DATABASE_URL,
+// or against an in-memory SQLite database when it is not set.
+func TestMain(m *testing.M) {
+	databaseURL = os.Getenv("DATABASE_URL")
+
+	os.Exit(m.Run())
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		db, teardown := sqlstore.TestDB(t, databaseURL)
+		t.Cleanup(func() { teardown("refresh_sessions", "users") })
+
+		return sqlstore.New(db)
+	})
+}
@@ -0,0 +1,24 @@
+package sqlstore
+
+import "time"
+
+// Sweep deletes the refresh sessions, authorization codes and one-time
+// tokens that expired before now, as Mongo does with TTL indexes, and
+// returns how many records it deleted.
+func (s *Store) Sweep(now time.Time) (int64, error) {
+	var total int64
+	for _, table := range []string{"refresh_sessions", "authorization_codes", "one_time_tokens"} {
+		res, err := s.db.Exec(`DELETE FROM `+table+` WHERE expires_at <= $1`, now.UTC())
+		if err != nil {
+			return total, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+
+	return total, nil
+}
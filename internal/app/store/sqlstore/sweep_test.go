@@ -0,0 +1,42 @@
+package sqlstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_Sweep(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("refresh_sessions", "one_time_tokens", "authorization_codes")
+
+	s := sqlstore.New(db)
+	now := time.Now()
+
+	assert.NoError(t, s.Token().CreateAuth("user", &model.TokenDetails{RefreshUuid: "expired", RtExpires: now.Add(-time.Second).Unix()}))
+	assert.NoError(t, s.Token().CreateAuth("user", &model.TokenDetails{RefreshUuid: "live", RtExpires: now.Add(time.Hour).Unix()}))
+	assert.NoError(t, s.OneTimeToken().Create(&model.OneTimeToken{Hash: "expired", Purpose: model.PurposeMagicLink, UserID: "user", ExpiresAt: now.Add(-time.Second)}))
+	assert.NoError(t, s.AuthorizationCode().Create(&model.AuthorizationCode{CodeHash: "live", ExpiresAt: now.Add(time.Minute)}))
+
+	sessions, _ := s.Token().FindByUser("user")
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, "live", sessions[0].RefreshUUID)
+	}
+
+	n, err := s.Sweep(now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	deleted, _ := s.Token().DeleteAuth("expired")
+	assert.Equal(t, int64(0), deleted)
+	_, err = s.OneTimeToken().Consume("expired", model.PurposeMagicLink)
+	assert.Equal(t, store.ErrRecordNotFound, err)
+	_, err = s.AuthorizationCode().Consume("live")
+	assert.NoError(t, err)
+	deleted, _ = s.Token().DeleteAuth("live")
+	assert.Equal(t, int64(1), deleted)
+}
@@ -0,0 +1,38 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// TestDB opens the database at databaseURL, or an in-memory SQLite
+// database when it is empty, and returns a teardown that empties the given
+// tables and closes it.
+func TestDB(t *testing.T, databaseURL string) (*sql.DB, func(...string)) {
+	t.Helper()
+
+	var db *sql.DB
+	var err error
+	if databaseURL == "" {
+		db, err = sql.Open("sqlite", "file::memory:")
+		if err == nil {
+			// Every connection to :memory: is a database of its own.
+			db.SetMaxOpenConns(1)
+			err = Migrate(db)
+		}
+	} else {
+		db, err = Open(databaseURL)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db, func(tables ...string) {
+		for _, table := range tables {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,64 @@
+package sqlstore
+
+import (
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// TokenRepository ...
+type TokenRepository struct {
+	store *Store
+}
+
+// CreateAuth ...
+func (r *TokenRepository) CreateAuth(userid string, td *model.TokenDetails) error {
+	_, err := r.store.db.Exec(
+		`INSERT INTO refresh_sessions (refresh_token, user_id, expires_at) VALUES ($1, $2, $3)`,
+		td.RefreshUuid, userid, time.Unix(td.RtExpires, 0).UTC(),
+	)
+
+	return err
+}
+
+// DeleteTokens ...
+func (r *TokenRepository) DeleteTokens(authD *model.AccessDetails) error {
+	_, err := r.store.db.Exec(`DELETE FROM refresh_sessions WHERE user_id = $1`, authD.UserID)
+
+	return err
+}
+
+// DeleteAuth ...
+func (r *TokenRepository) DeleteAuth(givenUuid string) (int64, error) {
+	res, err := r.store.db.Exec(`DELETE FROM refresh_sessions WHERE refresh_token = $1`, givenUuid)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// FindByUser leaves out the expired sessions Sweep has not deleted yet.
+func (r *TokenRepository) FindByUser(userID string) ([]*model.Session, error) {
+	rows, err := r.store.db.Query(
+		`SELECT refresh_token, user_id, expires_at FROM refresh_sessions
+		WHERE user_id = $1 AND expires_at > $2
+		ORDER BY expires_at`,
+		userID, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		s := &model.Session{}
+		if err := rows.Scan(&s.RefreshUUID, &s.UserID, (*timestamp)(&s.ExpiresAt)); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
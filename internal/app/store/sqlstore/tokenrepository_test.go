@@ -0,0 +1,42 @@
+package sqlstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenRepository(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("refresh_sessions")
+
+	s := sqlstore.New(db)
+	expires := time.Now().Add(time.Hour)
+
+	assert.NoError(t, s.Token().CreateAuth("user", &model.TokenDetails{RefreshUuid: "b", RtExpires: expires.Add(time.Minute).Unix()}))
+	assert.NoError(t, s.Token().CreateAuth("user", &model.TokenDetails{RefreshUuid: "a", RtExpires: expires.Unix()}))
+	assert.NoError(t, s.Token().CreateAuth("other", &model.TokenDetails{RefreshUuid: "c", RtExpires: expires.Unix()}))
+
+	sessions, err := s.Token().FindByUser("user")
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, "a", sessions[0].RefreshUUID)
+		assert.Equal(t, expires.Unix(), sessions[0].ExpiresAt.Unix())
+	}
+
+	deleted, err := s.Token().DeleteAuth("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	deleted, err = s.Token().DeleteAuth("a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	assert.NoError(t, s.Token().DeleteTokens(&model.AccessDetails{UserID: "user"}))
+	sessions, _ = s.Token().FindByUser("user")
+	assert.Empty(t, sessions)
+	sessions, _ = s.Token().FindByUser("other")
+	assert.Len(t, sessions, 1)
+}
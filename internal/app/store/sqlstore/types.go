@@ -0,0 +1,196 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"modernc.org/sqlite"
+)
+
+// SQLite extended result codes of constraint violations.
+const (
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+var errUnexpectedType = errors.New("unexpected column type")
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// objectID stores a primitive.ObjectID as its hex string, so that IDs look
+// the same in every store.
+type objectID primitive.ObjectID
+
+func (id objectID) Value() (driver.Value, error) {
+	return primitive.ObjectID(id).Hex(), nil
+}
+
+func (id *objectID) Scan(src interface{}) error {
+	var hex string
+	switch v := src.(type) {
+	case string:
+		hex = v
+	case []byte:
+		hex = string(v)
+	default:
+		return fmt.Errorf("%w: %T", errUnexpectedType, src)
+	}
+
+	oid, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return err
+	}
+	*id = objectID(oid)
+
+	return nil
+}
+
+// timestamp scans times that SQLite returns as text, which it does for
+// the rows of a RETURNING clause.
+type timestamp time.Time
+
+// timestampFormats are the layouts the SQLite driver writes times in, and
+// the one of column defaults. Times are always written in UTC, so that
+// their text sorts in time order.
+var timestampFormats = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+}
+
+func (t *timestamp) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case time.Time:
+		*t = timestamp(v)
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("%w: %T", errUnexpectedType, src)
+	}
+
+	var err error
+	for _, layout := range timestampFormats {
+		var parsed time.Time
+		if parsed, err = time.Parse(layout, text); err == nil {
+			*t = timestamp(parsed)
+			return nil
+		}
+	}
+
+	return err
+}
+
+// stringList stores a list of strings as a JSON array.
+type stringList []string
+
+func (l stringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+
+	b, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (l *stringList) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("%w: %T", errUnexpectedType, src)
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		list = nil
+	}
+	*l = list
+
+	return nil
+}
+
+// isUniqueViolation reports whether err is a duplicate key error of
+// PostgreSQL or SQLite.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqliteConstraintUnique || sqliteErr.Code() == sqliteConstraintPrimaryKey
+	}
+
+	return false
+}
+
+// query collects the arguments of a query built piece by piece.
+type query struct {
+	args []interface{}
+}
+
+// arg adds an argument and returns its placeholder.
+func (q *query) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// page returns the LIMIT and OFFSET clause of a page. Without a limit all
+// the remaining rows are returned.
+func (q *query) page(offset int, limit int) string {
+	if limit <= 0 {
+		limit = maxPageLimit
+	}
+
+	return " LIMIT " + q.arg(limit) + " OFFSET " + q.arg(offset)
+}
+
+// maxPageLimit stands for no limit, which PostgreSQL and SQLite spell
+// differently.
+const maxPageLimit = 1<<31 - 1
+
+// likePrefix returns a LIKE pattern matching strings that start with s.
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s) + "%"
+}
+
+// expectAffected returns ErrRecordNotFound if the statement changed no row.
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const userColumns = `id, email, email_verified, password, username, firstname, lastname, fullname,
+	roles, scopes, totp_secret, totp_enabled, totp_last_step, recovery_codes, status, created,
+	mfa_failures, mfa_last_failure`
+
+// useRecoveryCodeAttempts bounds the retries of UseRecoveryCode when the
+// codes of the user change under it.
+const useRecoveryCodeAttempts = 5
+
+// UserRepository ...
+type UserRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *UserRepository) Create(u *model.User) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	if err := u.BeforeCreate(); err != nil {
+		return err
+	}
+
+	u.ID = primitive.NewObjectID()
+	_, err := r.store.db.Exec(
+		`INSERT INTO users (`+userColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`,
+		userValues(u)...,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+
+	return err
+}
+
+// Update ...
+func (r *UserRepository) Update(u *model.User) error {
+	res, err := r.store.db.Exec(
+		`UPDATE users SET email = $2, email_verified = $3, password = $4, username = $5,
+			firstname = $6, lastname = $7, fullname = $8, roles = $9, scopes = $10,
+			totp_secret = $11, totp_enabled = $12, totp_last_step = $13, recovery_codes = $14,
+			status = $15, created = $16, mfa_failures = $17, mfa_last_failure = $18
+		WHERE id = $1`,
+		userValues(u)...,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res)
+}
+
+// UpdateStatus ...
+func (r *UserRepository) UpdateStatus(id string, status string) error {
+	return r.exec(`UPDATE users SET status = $2 WHERE id = $1`, id, status)
+}
+
+// UpdatePassword ...
+func (r *UserRepository) UpdatePassword(id string, encryptedPassword string) error {
+	return r.exec(`UPDATE users SET password = $2 WHERE id = $1`, id, encryptedPassword)
+}
+
+// MarkEmailVerified ...
+func (r *UserRepository) MarkEmailVerified(id string) error {
+	return r.exec(
+		`UPDATE users SET email_verified = TRUE,
+			status = CASE WHEN status = $2 THEN $3 ELSE status END
+		WHERE id = $1`,
+		id, model.UserStatusPendingVerification, model.UserStatusActive,
+	)
+}
+
+// UpdateTOTP ...
+func (r *UserRepository) UpdateTOTP(u *model.User) error {
+	return r.exec(
+		`UPDATE users SET totp_secret = $2, totp_enabled = $3, totp_last_step = $4, recovery_codes = $5
+		WHERE id = $1`,
+		u.ID.Hex(), u.TOTPSecret, u.TOTPEnabled, u.TOTPLastStep, stringList(u.RecoveryCodes),
+	)
+}
+
+// UseTOTPStep ...
+func (r *UserRepository) UseTOTPStep(id string, step int64) (bool, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return false, store.ErrRecordNotFound
+	}
+
+	res, err := r.store.db.Exec(
+		`UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`,
+		step, id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// UseRecoveryCode removes the code with a compare-and-swap of the whole
+// list, so that a code is used once even by concurrent requests.
+func (r *UserRepository) UseRecoveryCode(id string, codeHash string) (bool, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return false, store.ErrRecordNotFound
+	}
+
+	for i := 0; i < useRecoveryCodeAttempts; i++ {
+		var codes stringList
+		err := r.store.db.QueryRow(`SELECT recovery_codes FROM users WHERE id = $1`, id).Scan(&codes)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		var rest stringList
+		for _, code := range codes {
+			if code != codeHash {
+				rest = append(rest, code)
+			}
+		}
+		if len(rest) == len(codes) {
+			return false, nil
+		}
+
+		res, err := r.store.db.Exec(
+			`UPDATE users SET recovery_codes = $1 WHERE id = $2 AND recovery_codes = $3`,
+			rest, id, codes,
+		)
+		if err != nil {
+			return false, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 1 {
+			return n == 1, err
+		}
+	}
+
+	return false, store.ErrConflict
+}
+
+// AddMFAFailure ...
+func (r *UserRepository) AddMFAFailure(id string, at time.Time) (int, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return 0, store.ErrRecordNotFound
+	}
+
+	var failures int
+	err := r.store.db.QueryRow(
+		`UPDATE users SET mfa_failures = mfa_failures + 1, mfa_last_failure = $2
+		WHERE id = $1 RETURNING mfa_failures`,
+		id, at.UTC(),
+	).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return 0, store.ErrRecordNotFound
+	}
+
+	return failures, err
+}
+
+// ResetMFAFailures ...
+func (r *UserRepository) ResetMFAFailures(id string) error {
+	return r.exec(`UPDATE users SET mfa_failures = 0 WHERE id = $1`, id)
+}
+
+// Find ...
+func (r *UserRepository) Find(id string) (*model.User, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return scanUser(r.store.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+// FindByEmail ...
+func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
+	return scanUser(r.store.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
+
+// List ...
+func (r *UserRepository) List(f *model.UserFilter) ([]*model.User, error) {
+	q := &query{}
+	var where []string
+	if f.Status != "" {
+		where = append(where, "status = "+q.arg(f.Status))
+	}
+	if f.Query != "" {
+		prefix := q.arg(likePrefix(strings.ToLower(f.Query)))
+		where = append(where, "(LOWER(username) LIKE "+prefix+" ESCAPE '\\' OR LOWER(email) LIKE "+prefix+" ESCAPE '\\')")
+	}
+
+	sqlQuery := `SELECT ` + userColumns + ` FROM users`
+	if len(where) > 0 {
+		sqlQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	sqlQuery += " ORDER BY username, created DESC" + q.page(f.Offset, f.Limit)
+
+	rows, err := r.store.db.Query(sqlQuery, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*model.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// exec runs an update of the user whose id is the first argument.
+func (r *UserRepository) exec(query string, id string, args ...interface{}) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return store.ErrRecordNotFound
+	}
+
+	res, err := r.store.db.Exec(query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res)
+}
+
+func userValues(u *model.User) []interface{} {
+	return []interface{}{
+		objectID(u.ID),
+		u.Email,
+		u.EmailVerified,
+		u.EncryptedPassword,
+		u.Username,
+		u.FirstName,
+		u.LastName,
+		u.FullName,
+		stringList(u.Roles),
+		stringList(u.Scopes),
+		u.TOTPSecret,
+		u.TOTPEnabled,
+		u.TOTPLastStep,
+		stringList(u.RecoveryCodes),
+		u.Status,
+		u.Created.UTC(),
+		u.MFAFailures,
+		u.MFALastFailure.UTC(),
+	}
+}
+
+func scanUser(row scanner) (*model.User, error) {
+	u := &model.User{}
+	err := row.Scan(
+		(*objectID)(&u.ID),
+		&u.Email,
+		&u.EmailVerified,
+		&u.EncryptedPassword,
+		&u.Username,
+		&u.FirstName,
+		&u.LastName,
+		&u.FullName,
+		(*stringList)(&u.Roles),
+		(*stringList)(&u.Scopes),
+		&u.TOTPSecret,
+		&u.TOTPEnabled,
+		&u.TOTPLastStep,
+		(*stringList)(&u.RecoveryCodes),
+		&u.Status,
+		(*timestamp)(&u.Created),
+		&u.MFAFailures,
+		(*timestamp)(&u.MFALastFailure),
+	)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,178 @@
+package sqlstore_test
+
+import (
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserRepository_Create(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users")
+
+	s := sqlstore.New(db)
+	u := model.TestUser(t)
+	u.Roles = []string{"admin"}
+
+	assert.NoError(t, s.User().Create(u))
+	assert.False(t, u.ID.IsZero())
+
+	found, err := s.User().Find(u.ID.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, u.Email, found.Email)
+	assert.Equal(t, u.EncryptedPassword, found.EncryptedPassword)
+	assert.Equal(t, []string{"admin"}, found.Roles)
+	assert.Equal(t, model.UserStatusActive, found.Status)
+
+	assert.Equal(t, store.ErrConflict, s.User().Create(model.TestUser(t)))
+}
+
+func TestUserRepository_Find(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users")
+
+	s := sqlstore.New(db)
+
+	_, err := s.User().Find("000000000000000000000000")
+	assert.Equal(t, store.ErrRecordNotFound, err)
+	_, err = s.User().Find("not an id")
+	assert.Equal(t, store.ErrRecordNotFound, err)
+	_, err = s.User().FindByEmail("user@example.org")
+	assert.Equal(t, store.ErrRecordNotFound, err)
+
+	u := model.TestUser(t)
+	_ = s.User().Create(u)
+
+	found, err := s.User().FindByEmail(u.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, u.ID, found.ID)
+}
+
+func TestUserRepository_Update(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users")
+
+	s := sqlstore.New(db)
+	u := model.TestUser(t)
+	_ = s.User().Create(u)
+
+	u.Status = model.UserStatusDisabled
+	u.Scopes = []string{model.ScopeAdmin}
+	assert.NoError(t, s.User().Update(u))
+
+	found, _ := s.User().Find(u.ID.Hex())
+	assert.Equal(t, model.UserStatusDisabled, found.Status)
+	assert.Equal(t, []string{model.ScopeAdmin}, found.Scopes)
+
+	other := model.TestUser(t)
+	other.Email = "other@example.org"
+	assert.Equal(t, store.ErrRecordNotFound, s.User().Update(other))
+}
+
+func TestUserRepository_UseTOTPStep(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users")
+
+	s := sqlstore.New(db)
+	u := model.TestUser(t)
+	_ = s.User().Create(u)
+
+	for _, tc := range []struct {
+		step     int64
+		expected bool
+	}{
+		{step: 10, expected: true},
+		{step: 10, expected: false},
+		{step: 9, expected: false},
+		{step: 11, expected: true},
+	} {
+		ok, err := s.User().UseTOTPStep(u.ID.Hex(), tc.step)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, ok, tc.step)
+	}
+}
+
+func TestUserRepository_UseRecoveryCode(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users")
+
+	s := sqlstore.New(db)
+	u := model.TestUser(t)
+	u.RecoveryCodes = []string{"a", "b"}
+	_ = s.User().Create(u)
+
+	ok, err := s.User().UseRecoveryCode(u.ID.Hex(), "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, _ = s.User().UseRecoveryCode(u.ID.Hex(), "a")
+	assert.False(t, ok)
+
+	found, _ := s.User().Find(u.ID.Hex())
+	assert.Equal(t, []string{"b"}, found.RecoveryCodes)
+}
+
+func TestUserRepository_List(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("users")
+
+	s := sqlstore.New(db)
+	hash := model.TestUser(t).EncryptedPassword
+	for _, u := range []*model.User{
+		{Email: "carol@example.org", Username: "carol", EncryptedPassword: hash, Status: model.UserStatusDisabled},
+		{Email: "alice@example.org", Username: "alice", EncryptedPassword: hash},
+		{Email: "bob@example.org", Username: "Bob_", EncryptedPassword: hash},
+	} {
+		if err := s.User().Create(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		filter   *model.UserFilter
+		expected []string
+	}{
+		{
+			name:     "all",
+			filter:   &model.UserFilter{},
+			expected: []string{"Bob_", "alice", "carol"},
+		},
+		{
+			name:     "page",
+			filter:   &model.UserFilter{Offset: 1, Limit: 1},
+			expected: []string{"alice"},
+		},
+		{
+			name:     "query",
+			filter:   &model.UserFilter{Query: "BOB"},
+			expected: []string{"Bob_"},
+		},
+		{
+			name:     "query is not a pattern",
+			filter:   &model.UserFilter{Query: "_"},
+			expected: nil,
+		},
+		{
+			name:     "status",
+			filter:   &model.UserFilter{Status: model.UserStatusDisabled},
+			expected: []string{"carol"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			users, err := s.User().List(tc.filter)
+			assert.NoError(t, err)
+
+			var usernames []string
+			for _, u := range users {
+				usernames = append(usernames, u.Username)
+			}
+			assert.Equal(t, tc.expected, usernames)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt, last_error, created`
+
+// WebhookRepository ...
+type WebhookRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *WebhookRepository) Create(w *model.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	if w.Created.IsZero() {
+		w.Created = time.Now()
+	}
+
+	w.ID = primitive.NewObjectID()
+	_, err := r.store.db.Exec(
+		`INSERT INTO webhooks (id, url, events, secret, created) VALUES ($1, $2, $3, $4, $5)`,
+		objectID(w.ID), w.URL, stringList(w.Events), w.Secret, w.Created.UTC(),
+	)
+
+	return err
+}
+
+// Find ...
+func (r *WebhookRepository) Find(id string) (*model.Webhook, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	w := &model.Webhook{}
+	err := r.store.db.QueryRow(
+		`SELECT id, url, events, secret, created FROM webhooks WHERE id = $1`,
+		id,
+	).Scan((*objectID)(&w.ID), &w.URL, (*stringList)(&w.Events), &w.Secret, (*timestamp)(&w.Created))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// List ...
+func (r *WebhookRepository) List() ([]*model.Webhook, error) {
+	rows, err := r.store.db.Query(`SELECT id, url, events, secret, created FROM webhooks ORDER BY created`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*model.Webhook
+	for rows.Next() {
+		w := &model.Webhook{}
+		if err := rows.Scan((*objectID)(&w.ID), &w.URL, (*stringList)(&w.Events), &w.Secret, (*timestamp)(&w.Created)); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// FindByEvent filters the webhooks in Go, as events are kept as a JSON
+// list and there are few webhooks.
+func (r *WebhookRepository) FindByEvent(event string) ([]*model.Webhook, error) {
+	webhooks, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var subscribed []*model.Webhook
+	for _, w := range webhooks {
+		if w.Subscribes(event) {
+			subscribed = append(subscribed, w)
+		}
+	}
+
+	return subscribed, nil
+}
+
+// Delete ...
+func (r *WebhookRepository) Delete(id string) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return store.ErrRecordNotFound
+	}
+
+	res, err := r.store.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res)
+}
+
+// WebhookDeliveryRepository ...
+type WebhookDeliveryRepository struct {
+	store *Store
+}
+
+// Create ...
+func (r *WebhookDeliveryRepository) Create(d *model.WebhookDelivery) error {
+	if d.ID.IsZero() {
+		d.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.store.db.Exec(
+		`INSERT INTO webhook_deliveries (`+webhookDeliveryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		objectID(d.ID),
+		objectID(d.WebhookID),
+		d.Event,
+		d.Payload,
+		d.Status,
+		d.Attempts,
+		d.NextAttempt.UTC(),
+		d.LastError,
+		d.Created.UTC(),
+	)
+
+	return err
+}
+
+// Claim takes the earliest due delivery in a single statement. When two
+// workers pick the same one, the loser gets ErrRecordNotFound and retries
+// on its next poll.
+func (r *WebhookDeliveryRepository) Claim(now time.Time, lease time.Duration) (*model.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.store.db.QueryRow(
+		`UPDATE webhook_deliveries SET next_attempt = $1
+		WHERE id = (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $2 AND next_attempt <= $3
+			ORDER BY next_attempt
+			LIMIT 1
+		) AND status = $2 AND next_attempt <= $3
+		RETURNING `+webhookDeliveryColumns,
+		now.Add(lease).UTC(), model.DeliveryPending, now.UTC(),
+	))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return d, err
+}
+
+// Update ...
+func (r *WebhookDeliveryRepository) Update(d *model.WebhookDelivery) error {
+	res, err := r.store.db.Exec(
+		`UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt = $4, last_error = $5
+		WHERE id = $1`,
+		objectID(d.ID), d.Status, d.Attempts, d.NextAttempt.UTC(), d.LastError,
+	)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res)
+}
+
+// FindByWebhook ...
+func (r *WebhookDeliveryRepository) FindByWebhook(webhookID string, status string) ([]*model.WebhookDelivery, error) {
+	if _, err := primitive.ObjectIDFromHex(webhookID); err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	rows, err := r.store.db.Query(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND status = $2 ORDER BY created`,
+		webhookID, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func scanWebhookDelivery(row scanner) (*model.WebhookDelivery, error) {
+	d := &model.WebhookDelivery{}
+	if err := row.Scan(
+		(*objectID)(&d.ID),
+		(*objectID)(&d.WebhookID),
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		(*timestamp)(&d.NextAttempt),
+		&d.LastError,
+		(*timestamp)(&d.Created),
+	); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
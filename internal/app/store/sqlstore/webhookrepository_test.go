@@ -0,0 +1,55 @@
+package sqlstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookRepository(t *testing.T) {
+	db, teardown := sqlstore.TestDB(t, databaseURL)
+	defer teardown("webhooks", "webhook_deliveries")
+
+	s := sqlstore.New(db)
+	w := &model.Webhook{URL: "https://example.org/hook", Events: []string{model.WebhookLogin}, Secret: "secret"}
+	assert.NoError(t, s.Webhook().Create(w))
+
+	webhooks, err := s.Webhook().FindByEvent(model.WebhookLogin)
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 1)
+	webhooks, _ = s.Webhook().FindByEvent(model.WebhookLogoutAll)
+	assert.Empty(t, webhooks)
+
+	now := time.Now().UTC()
+	d := &model.WebhookDelivery{
+		WebhookID:   w.ID,
+		Event:       model.WebhookLogin,
+		Payload:     "{}",
+		Status:      model.DeliveryPending,
+		NextAttempt: now,
+		Created:     now,
+	}
+	assert.NoError(t, s.WebhookDelivery().Create(d))
+
+	claimed, err := s.WebhookDelivery().Claim(now, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, d.ID, claimed.ID)
+	_, err = s.WebhookDelivery().Claim(now, time.Minute)
+	assert.Equal(t, store.ErrRecordNotFound, err)
+
+	claimed.Status = model.DeliveryDead
+	claimed.Attempts = 8
+	assert.NoError(t, s.WebhookDelivery().Update(claimed))
+	dead, err := s.WebhookDelivery().FindByWebhook(w.ID.Hex(), model.DeliveryDead)
+	assert.NoError(t, err)
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, 8, dead[0].Attempts)
+	}
+
+	assert.NoError(t, s.Webhook().Delete(w.ID.Hex()))
+	assert.Equal(t, store.ErrRecordNotFound, s.Webhook().Delete(w.ID.Hex()))
+}
@@ -2,5 +2,12 @@ package store
 
 // Store ...
 type Store interface {
+	User() UserRepository
 	Token() TokenRepository
+	Client() ClientRepository
+	AuthorizationCode() AuthorizationCodeRepository
+	OneTimeToken() OneTimeTokenRepository
+	Audit() AuditRepository
+	Webhook() WebhookRepository
+	WebhookDelivery() WebhookDeliveryRepository
 }
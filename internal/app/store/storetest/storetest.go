@@ -0,0 +1,320 @@
+// Package storetest holds the tests every store.Store implementation must
+// pass. A backend runs them from its own tests with a constructor:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store {
+//			return teststore.New()
+//		})
+//	}
+package storetest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrency is the number of goroutines of the concurrent tests.
+const concurrency = 20
+
+// Run runs the conformance tests against the stores newStore returns. It
+// is called once per test and must return an empty store.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	testCases := []struct {
+		name string
+		test func(t *testing.T, s store.Store)
+	}{
+		{name: "TokenRepository/CreateAuth", test: testCreateAuth},
+		{name: "TokenRepository/DeleteAuth", test: testDeleteAuth},
+		{name: "TokenRepository/DeleteTokens", test: testDeleteTokens},
+		{name: "TokenRepository/Expiry", test: testExpiry},
+		{name: "TokenRepository/ConcurrentCreateAuth", test: testConcurrentCreateAuth},
+		{name: "TokenRepository/ConcurrentDeleteAuth", test: testConcurrentDeleteAuth},
+		{name: "UserRepository/TargetedUpdates", test: testUserTargetedUpdates},
+		{name: "UserRepository/MFAFailures", test: testUserMFAFailures},
+		{name: "OneTimeTokenRepository/FindAndConsume", test: testOneTimeTokenFindAndConsume},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+func createAuth(t *testing.T, repo store.TokenRepository, userID string, refreshUUID string, expires time.Time) {
+	t.Helper()
+
+	if err := repo.CreateAuth(userID, &model.TokenDetails{RefreshUuid: refreshUUID, RtExpires: expires.Unix()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func refreshUUIDs(t *testing.T, repo store.TokenRepository, userID string) []string {
+	t.Helper()
+
+	sessions, err := repo.FindByUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uuids []string
+	for _, s := range sessions {
+		uuids = append(uuids, s.RefreshUUID)
+	}
+
+	return uuids
+}
+
+func testCreateAuth(t *testing.T, s store.Store) {
+	repo := s.Token()
+	expires := time.Now().Add(time.Hour)
+
+	createAuth(t, repo, "user", "later", expires.Add(time.Minute))
+	createAuth(t, repo, "user", "sooner", expires)
+	createAuth(t, repo, "other", "other", expires)
+
+	sessions, err := repo.FindByUser("user")
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, "sooner", sessions[0].RefreshUUID)
+		assert.Equal(t, "user", sessions[0].UserID)
+		assert.Equal(t, expires.Unix(), sessions[0].ExpiresAt.Unix())
+		assert.Equal(t, "later", sessions[1].RefreshUUID)
+	}
+
+	assert.Empty(t, refreshUUIDs(t, repo, "nobody"))
+}
+
+// testDeleteAuth checks that a session is deleted once: the refresh handler
+// treats deleted == 0 as a reused refresh token.
+func testDeleteAuth(t *testing.T, s store.Store) {
+	repo := s.Token()
+	expires := time.Now().Add(time.Hour)
+	createAuth(t, repo, "user", "a", expires)
+	createAuth(t, repo, "user", "b", expires)
+
+	testCases := []struct {
+		name        string
+		refreshUUID string
+		deleted     int64
+	}{
+		{name: "existing", refreshUUID: "a", deleted: 1},
+		{name: "already deleted", refreshUUID: "a", deleted: 0},
+		{name: "unknown", refreshUUID: "unknown", deleted: 0},
+	}
+
+	for _, tc := range testCases {
+		deleted, err := repo.DeleteAuth(tc.refreshUUID)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.deleted, deleted, tc.name)
+	}
+
+	assert.Equal(t, []string{"b"}, refreshUUIDs(t, repo, "user"))
+}
+
+func testDeleteTokens(t *testing.T, s store.Store) {
+	repo := s.Token()
+	expires := time.Now().Add(time.Hour)
+	createAuth(t, repo, "user", "a", expires)
+	createAuth(t, repo, "user", "b", expires.Add(time.Minute))
+	createAuth(t, repo, "other", "c", expires)
+
+	assert.NoError(t, repo.DeleteTokens(&model.AccessDetails{UserID: "user"}))
+
+	assert.Empty(t, refreshUUIDs(t, repo, "user"))
+	for _, refreshUUID := range []string{"a", "b"} {
+		deleted, err := repo.DeleteAuth(refreshUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), deleted, refreshUUID)
+	}
+	assert.Equal(t, []string{"c"}, refreshUUIDs(t, repo, "other"))
+
+	assert.NoError(t, repo.DeleteTokens(&model.AccessDetails{UserID: "nobody"}))
+}
+
+// testExpiry checks that sessions whose refresh token has expired are no
+// longer listed, whether the backend deletes them or filters them out.
+func testExpiry(t *testing.T, s store.Store) {
+	repo := s.Token()
+	now := time.Now()
+	createAuth(t, repo, "user", "live", now.Add(time.Hour))
+	createAuth(t, repo, "user", "expired", now.Add(-time.Minute))
+
+	assert.Equal(t, []string{"live"}, refreshUUIDs(t, repo, "user"))
+}
+
+func testConcurrentCreateAuth(t *testing.T, s store.Store) {
+	repo := s.Token()
+	expires := time.Now().Add(time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := repo.CreateAuth("user", &model.TokenDetails{
+				RefreshUuid: fmt.Sprintf("session-%d", i),
+				RtExpires:   expires.Unix(),
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, refreshUUIDs(t, repo, "user"), concurrency)
+}
+
+// testConcurrentDeleteAuth checks that of concurrent refreshes with the
+// same token exactly one wins.
+func testConcurrentDeleteAuth(t *testing.T, s store.Store) {
+	repo := s.Token()
+	createAuth(t, repo, "user", "a", time.Now().Add(time.Hour))
+
+	var wg sync.WaitGroup
+	var total int64
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deleted, err := repo.DeleteAuth("a")
+			assert.NoError(t, err)
+			atomic.AddInt64(&total, deleted)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), total)
+}
+
+// testUserTargetedUpdates checks that each targeted update leaves the
+// fields it does not name as they are in the store, not as in a copy of the
+// user read earlier.
+func testUserTargetedUpdates(t *testing.T, s store.Store) {
+	repo := s.User()
+	u := model.TestUser(t)
+	u.Status = model.UserStatusPendingVerification
+	u.EmailVerified = false
+	if err := repo.Create(u); err != nil {
+		t.Fatal(err)
+	}
+	id := u.ID.Hex()
+	stale := *u
+	stale.TOTPSecret = "secret"
+	stale.TOTPEnabled = true
+	stale.RecoveryCodes = []string{"code"}
+
+	assert.NoError(t, repo.UpdateStatus(id, model.UserStatusDisabled))
+	assert.NoError(t, repo.UpdatePassword(id, "hash"))
+	assert.NoError(t, repo.MarkEmailVerified(id))
+	assert.NoError(t, repo.UpdateTOTP(&stale))
+
+	found, err := repo.Find(id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.UserStatusDisabled, found.Status)
+		assert.Equal(t, "hash", found.EncryptedPassword)
+		assert.True(t, found.EmailVerified)
+		assert.Equal(t, "secret", found.TOTPSecret)
+		assert.True(t, found.TOTPEnabled)
+		assert.Equal(t, []string{"code"}, found.RecoveryCodes)
+	}
+
+	other := model.TestUser(t)
+	other.Email = "pending@example.org"
+	other.Status = model.UserStatusPendingVerification
+	if err := repo.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, repo.MarkEmailVerified(other.ID.Hex()))
+	found, err = repo.Find(other.ID.Hex())
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.UserStatusActive, found.Status)
+	}
+
+	unknown := "000000000000000000000000"
+	assert.Equal(t, store.ErrRecordNotFound, repo.UpdateStatus(unknown, model.UserStatusDisabled))
+	assert.Equal(t, store.ErrRecordNotFound, repo.UpdatePassword(unknown, "hash"))
+	assert.Equal(t, store.ErrRecordNotFound, repo.MarkEmailVerified(unknown))
+}
+
+// testUserMFAFailures checks that concurrent failures are all counted, so
+// that the MFA lockout cannot be outrun by parallel guesses.
+func testUserMFAFailures(t *testing.T, s store.Store) {
+	repo := s.User()
+	u := model.TestUser(t)
+	if err := repo.Create(u); err != nil {
+		t.Fatal(err)
+	}
+	id := u.ID.Hex()
+	at := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.AddMFAFailure(id, at)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	failures, err := repo.AddMFAFailure(id, at)
+	assert.NoError(t, err)
+	assert.Equal(t, concurrency+1, failures)
+	found, err := repo.Find(id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, concurrency+1, found.MFAFailures)
+		assert.True(t, at.Equal(found.MFALastFailure))
+	}
+
+	assert.NoError(t, repo.ResetMFAFailures(id))
+	found, err = repo.Find(id)
+	if assert.NoError(t, err) {
+		assert.Zero(t, found.MFAFailures)
+	}
+
+	unknown := "000000000000000000000000"
+	_, err = repo.AddMFAFailure(unknown, at)
+	assert.Equal(t, store.ErrRecordNotFound, err)
+	assert.Equal(t, store.ErrRecordNotFound, repo.ResetMFAFailures(unknown))
+}
+
+// testOneTimeTokenFindAndConsume checks that Find leaves the token usable
+// and that Consume uses it up.
+func testOneTimeTokenFindAndConsume(t *testing.T, s store.Store) {
+	repo := s.OneTimeToken()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := repo.Create(&model.OneTimeToken{
+		Hash:      "hash",
+		Purpose:   model.PurposePasswordReset,
+		UserID:    "user",
+		ExpiresAt: expires,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := repo.Find("hash", model.PurposeMagicLink)
+	assert.Equal(t, store.ErrRecordNotFound, err)
+
+	for i := 0; i < 2; i++ {
+		found, err := repo.Find("hash", model.PurposePasswordReset)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "user", found.UserID)
+			assert.True(t, expires.Equal(found.ExpiresAt))
+		}
+	}
+
+	_, err = repo.Consume("hash", model.PurposePasswordReset)
+	assert.NoError(t, err)
+	_, err = repo.Find("hash", model.PurposePasswordReset)
+	assert.Equal(t, store.ErrRecordNotFound, err)
+	_, err = repo.Consume("hash", model.PurposePasswordReset)
+	assert.Equal(t, store.ErrRecordNotFound, err)
+}
@@ -0,0 +1,119 @@
+package teststore
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditRepository ...
+type AuditRepository struct {
+	store       *Store
+	mu          sync.Mutex
+	events      []*model.AuditEvent
+	checkpoints []*model.AuditCheckpoint
+}
+
+// Create ...
+func (r *AuditRepository) Create(e *model.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e.Seq > 0 {
+		for _, existing := range r.events {
+			if existing.Seq == e.Seq {
+				return store.ErrConflict
+			}
+		}
+	}
+
+	e.ID = primitive.NewObjectID()
+	r.events = append(r.events, e)
+
+	return nil
+}
+
+// Find ...
+func (r *AuditRepository) Find(f *model.AuditFilter) ([]*model.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []*model.AuditEvent
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if f.Matches(r.events[i]) {
+			events = append(events, r.events[i])
+		}
+	}
+
+	if f.Offset >= len(events) {
+		return nil, nil
+	}
+	events = events[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(events) {
+		events = events[:f.Limit]
+	}
+
+	return events, nil
+}
+
+// Last ...
+func (r *AuditRepository) Last() (*model.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *model.AuditEvent
+	for _, e := range r.events {
+		if last == nil || e.Seq > last.Seq {
+			last = e
+		}
+	}
+	if last == nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return last, nil
+}
+
+// Walk ...
+func (r *AuditRepository) Walk(fn func(*model.AuditEvent) error) error {
+	r.mu.Lock()
+	events := append([]*model.AuditEvent{}, r.events...)
+	r.mu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Seq < events[j].Seq
+	})
+	for _, e := range events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateCheckpoint ...
+func (r *AuditRepository) CreateCheckpoint(c *model.AuditCheckpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkpoints = append(r.checkpoints, c)
+
+	return nil
+}
+
+// FindCheckpoints ...
+func (r *AuditRepository) FindCheckpoints() ([]*model.AuditCheckpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	checkpoints := append([]*model.AuditCheckpoint{}, r.checkpoints...)
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Seq < checkpoints[j].Seq
+	})
+
+	return checkpoints, nil
+}
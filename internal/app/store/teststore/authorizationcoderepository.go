@@ -0,0 +1,31 @@
+package teststore
+
+import (
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// AuthorizationCodeRepository ...
+type AuthorizationCodeRepository struct {
+	store *Store
+	codes map[string]*model.AuthorizationCode
+}
+
+// Create ...
+func (r *AuthorizationCodeRepository) Create(c *model.AuthorizationCode) error {
+	r.codes[c.CodeHash] = c
+
+	return nil
+}
+
+// Consume ...
+func (r *AuthorizationCodeRepository) Consume(codeHash string) (*model.AuthorizationCode, error) {
+	c, ok := r.codes[codeHash]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+
+	delete(r.codes, codeHash)
+
+	return c, nil
+}
@@ -0,0 +1,37 @@
+package teststore
+
+import (
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// ClientRepository ...
+type ClientRepository struct {
+	store   *Store
+	clients map[string]*model.Client
+}
+
+// Create ...
+func (r *ClientRepository) Create(c *model.Client) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.BeforeCreate(); err != nil {
+		return err
+	}
+
+	r.clients[c.ID] = c
+
+	return nil
+}
+
+// Find ...
+func (r *ClientRepository) Find(id string) (*model.Client, error) {
+	c, ok := r.clients[id]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return c, nil
+}
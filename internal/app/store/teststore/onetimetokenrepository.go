@@ -0,0 +1,52 @@
+package teststore
+
+import (
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+)
+
+// OneTimeTokenRepository ...
+type OneTimeTokenRepository struct {
+	store  *Store
+	tokens map[string]*model.OneTimeToken
+}
+
+// Create ...
+func (r *OneTimeTokenRepository) Create(t *model.OneTimeToken) error {
+	r.tokens[t.Hash] = t
+
+	return nil
+}
+
+// Find ...
+func (r *OneTimeTokenRepository) Find(hash string, purpose string) (*model.OneTimeToken, error) {
+	t, ok := r.tokens[hash]
+	if !ok || t.Purpose != purpose {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return t, nil
+}
+
+// Consume ...
+func (r *OneTimeTokenRepository) Consume(hash string, purpose string) (*model.OneTimeToken, error) {
+	t, ok := r.tokens[hash]
+	if !ok || t.Purpose != purpose {
+		return nil, store.ErrRecordNotFound
+	}
+
+	delete(r.tokens, hash)
+
+	return t, nil
+}
+
+// DeleteAll ...
+func (r *OneTimeTokenRepository) DeleteAll(userID string, purpose string) error {
+	for hash, t := range r.tokens {
+		if t.UserID == userID && t.Purpose == purpose {
+			delete(r.tokens, hash)
+		}
+	}
+
+	return nil
+}
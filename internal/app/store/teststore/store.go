@@ -3,11 +3,19 @@ package teststore
 import (
 	"github.com/psihachina/go-test-work.git/internal/app/model"
 	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Store ...
 type Store struct {
-	userRepository *UserRepository
+	userRepository              *UserRepository
+	tokenRepository             *TokenRepository
+	clientRepository            *ClientRepository
+	authorizationCodeRepository *AuthorizationCodeRepository
+	oneTimeTokenRepository      *OneTimeTokenRepository
+	auditRepository             *AuditRepository
+	webhookRepository           *WebhookRepository
+	webhookDeliveryRepository   *WebhookDeliveryRepository
 }
 
 // New ...
@@ -28,3 +36,100 @@ func (s *Store) User() store.UserRepository {
 
 	return s.userRepository
 }
+
+// Token ...
+func (s *Store) Token() store.TokenRepository {
+	if s.tokenRepository != nil {
+		return s.tokenRepository
+	}
+
+	s.tokenRepository = &TokenRepository{
+		store:    s,
+		sessions: make(map[string]*model.Session),
+	}
+
+	return s.tokenRepository
+}
+
+// Client ...
+func (s *Store) Client() store.ClientRepository {
+	if s.clientRepository != nil {
+		return s.clientRepository
+	}
+
+	s.clientRepository = &ClientRepository{
+		store:   s,
+		clients: make(map[string]*model.Client),
+	}
+
+	return s.clientRepository
+}
+
+// AuthorizationCode ...
+func (s *Store) AuthorizationCode() store.AuthorizationCodeRepository {
+	if s.authorizationCodeRepository != nil {
+		return s.authorizationCodeRepository
+	}
+
+	s.authorizationCodeRepository = &AuthorizationCodeRepository{
+		store: s,
+		codes: make(map[string]*model.AuthorizationCode),
+	}
+
+	return s.authorizationCodeRepository
+}
+
+// OneTimeToken ...
+func (s *Store) OneTimeToken() store.OneTimeTokenRepository {
+	if s.oneTimeTokenRepository != nil {
+		return s.oneTimeTokenRepository
+	}
+
+	s.oneTimeTokenRepository = &OneTimeTokenRepository{
+		store:  s,
+		tokens: make(map[string]*model.OneTimeToken),
+	}
+
+	return s.oneTimeTokenRepository
+}
+
+// Audit ...
+func (s *Store) Audit() store.AuditRepository {
+	if s.auditRepository != nil {
+		return s.auditRepository
+	}
+
+	s.auditRepository = &AuditRepository{
+		store: s,
+	}
+
+	return s.auditRepository
+}
+
+// Webhook ...
+func (s *Store) Webhook() store.WebhookRepository {
+	if s.webhookRepository != nil {
+		return s.webhookRepository
+	}
+
+	s.webhookRepository = &WebhookRepository{
+		store:    s,
+		webhooks: make(map[primitive.ObjectID]*model.Webhook),
+	}
+
+	return s.webhookRepository
+}
+
+// WebhookDelivery ...
+func (s *Store) WebhookDelivery() store.WebhookDeliveryRepository {
+	if s.webhookDeliveryRepository != nil {
+		return s.webhookDeliveryRepository
+	}
+
+	s.webhookDeliveryRepository = &WebhookDeliveryRepository{
+		store:      s,
+		deliveries: make(map[primitive.ObjectID]*model.WebhookDelivery),
+	}
+
+	return s.webhookDeliveryRepository
+}
@@ -0,0 +1,15 @@
+package teststore_test
+
+import (
+	"testing"
+
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"github.com/psihachina/go-test-work.git/internal/app/store/storetest"
+	"github.com/psihachina/go-test-work.git/internal/app/store/teststore"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return teststore.New()
+	})
+}
@@ -0,0 +1,78 @@
+package teststore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+)
+
+// TokenRepository ...
+type TokenRepository struct {
+	store    *Store
+	mu       sync.Mutex
+	sessions map[string]*model.Session
+}
+
+// CreateAuth ...
+func (r *TokenRepository) CreateAuth(userid string, td *model.TokenDetails) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[td.RefreshUuid] = &model.Session{
+		RefreshUUID: td.RefreshUuid,
+		UserID:      userid,
+		ExpiresAt:   time.Unix(td.RtExpires, 0),
+	}
+
+	return nil
+}
+
+// DeleteTokens ...
+func (r *TokenRepository) DeleteTokens(authD *model.AccessDetails) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for refreshUUID, session := range r.sessions {
+		if session.UserID == authD.UserID {
+			delete(r.sessions, refreshUUID)
+		}
+	}
+
+	return nil
+}
+
+// DeleteAuth ...
+func (r *TokenRepository) DeleteAuth(givenUuid string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[givenUuid]; !ok {
+		return 0, nil
+	}
+
+	delete(r.sessions, givenUuid)
+
+	return 1, nil
+}
+
+// FindByUser ...
+func (r *TokenRepository) FindByUser(userID string) ([]*model.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var sessions []*model.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ExpiresAt.Before(sessions[j].ExpiresAt)
+	})
+
+	return sessions, nil
+}
@@ -1,6 +1,11 @@
 package teststore
 
 import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/psihachina/go-test-work.git/internal/app/model"
 	"github.com/psihachina/go-test-work.git/internal/app/store"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,6 +15,8 @@ import (
 type UserRepository struct {
 	store *Store
 	users map[string]*model.User
+	// mu serializes the counters that concurrent requests update.
+	mu sync.Mutex
 }
 
 // Create ...
@@ -28,6 +35,141 @@ func (r *UserRepository) Create(u *model.User) error {
 	return nil
 }
 
+// Update ...
+func (r *UserRepository) Update(u *model.User) error {
+	for email, existing := range r.users {
+		if existing.ID == u.ID {
+			delete(r.users, email)
+			r.users[u.Email] = u
+			return nil
+		}
+	}
+
+	return store.ErrRecordNotFound
+}
+
+// UpdateStatus ...
+func (r *UserRepository) UpdateStatus(id string, status string) error {
+	u, err := r.Find(id)
+	if err != nil {
+		return err
+	}
+	u.Status = status
+
+	return nil
+}
+
+// UpdatePassword ...
+func (r *UserRepository) UpdatePassword(id string, encryptedPassword string) error {
+	u, err := r.Find(id)
+	if err != nil {
+		return err
+	}
+	u.EncryptedPassword = encryptedPassword
+
+	return nil
+}
+
+// MarkEmailVerified ...
+func (r *UserRepository) MarkEmailVerified(id string) error {
+	u, err := r.Find(id)
+	if err != nil {
+		return err
+	}
+	u.EmailVerified = true
+	if u.Status == model.UserStatusPendingVerification {
+		u.Status = model.UserStatusActive
+	}
+
+	return nil
+}
+
+// UpdateTOTP ...
+func (r *UserRepository) UpdateTOTP(u *model.User) error {
+	existing, err := r.Find(u.ID.Hex())
+	if err != nil {
+		return err
+	}
+	existing.TOTPSecret = u.TOTPSecret
+	existing.TOTPEnabled = u.TOTPEnabled
+	existing.TOTPLastStep = u.TOTPLastStep
+	existing.RecoveryCodes = u.RecoveryCodes
+
+	return nil
+}
+
+// UseTOTPStep ...
+func (r *UserRepository) UseTOTPStep(id string, step int64) (bool, error) {
+	u, err := r.Find(id)
+	if err != nil {
+		return false, err
+	}
+
+	if u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+
+	return true, nil
+}
+
+// UseRecoveryCode ...
+func (r *UserRepository) UseRecoveryCode(id string, codeHash string) (bool, error) {
+	u, err := r.Find(id)
+	if err != nil {
+		return false, err
+	}
+
+	for i, hash := range u.RecoveryCodes {
+		if hash == codeHash {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// AddMFAFailure ...
+func (r *UserRepository) AddMFAFailure(id string, at time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, err := r.Find(id)
+	if err != nil {
+		return 0, err
+	}
+	u.MFAFailures++
+	u.MFALastFailure = at
+
+	return u.MFAFailures, nil
+}
+
+// ResetMFAFailures ...
+func (r *UserRepository) ResetMFAFailures(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, err := r.Find(id)
+	if err != nil {
+		return err
+	}
+	u.MFAFailures = 0
+
+	return nil
+}
+
+// Find ...
+func (r *UserRepository) Find(id string) (*model.User, error) {
+	for _, u := range r.users {
+		if u.ID.Hex() == id {
+			return u, nil
+		}
+	}
+
+	return nil, store.ErrRecordNotFound
+}
+
 // FindByEmail ...
 func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
 	u, ok := r.users[email]
@@ -39,6 +181,41 @@ func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
 	return u, nil
 }
 
+// List ...
+func (r *UserRepository) List(f *model.UserFilter) ([]*model.User, error) {
+	query := strings.ToLower(f.Query)
+
+	var users []*model.User
+	for _, u := range r.users {
+		if f.Status != "" && u.Status != f.Status {
+			continue
+		}
+		if query != "" &&
+			!strings.HasPrefix(strings.ToLower(u.Username), query) &&
+			!strings.HasPrefix(strings.ToLower(u.Email), query) {
+			continue
+		}
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Username != users[j].Username {
+			return users[i].Username < users[j].Username
+		}
+		return users[i].Created.After(users[j].Created)
+	})
+
+	if f.Offset >= len(users) {
+		return nil, nil
+	}
+	users = users[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(users) {
+		users = users[:f.Limit]
+	}
+
+	return users, nil
+}
+
 type Fields struct {
 	ID       primitive.ObjectID
 	Email    string
@@ -36,3 +36,35 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, u)
 }
+
+func TestUserRepository_List(t *testing.T) {
+	s := teststore.New()
+
+	for _, name := range []string{"carol", "alice", "bob", "alex"} {
+		u := model.TestUser(t)
+		u.Email = name + "@example.org"
+		u.Username = name
+		assert.NoError(t, s.User().Create(u))
+	}
+
+	users, err := s.User().List(&model.UserFilter{Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, "alex", users[0].Username)
+		assert.Equal(t, "alice", users[1].Username)
+	}
+
+	users, err = s.User().List(&model.UserFilter{Offset: 2, Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, "bob", users[0].Username)
+	}
+
+	users, err = s.User().List(&model.UserFilter{Query: "AL"})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	users, err = s.User().List(&model.UserFilter{Status: model.UserStatusDisabled})
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+}
@@ -0,0 +1,178 @@
+package teststore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/psihachina/go-test-work.git/internal/app/model"
+	"github.com/psihachina/go-test-work.git/internal/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookRepository ...
+type WebhookRepository struct {
+	store    *Store
+	mu       sync.Mutex
+	webhooks map[primitive.ObjectID]*model.Webhook
+}
+
+// Create ...
+func (r *WebhookRepository) Create(w *model.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w.ID = primitive.NewObjectID()
+	if w.Created.IsZero() {
+		w.Created = time.Now()
+	}
+	copied := *w
+	r.webhooks[w.ID] = &copied
+
+	return nil
+}
+
+// Find ...
+func (r *WebhookRepository) Find(id string) (*model.Webhook, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.webhooks[oid]
+	if !ok {
+		return nil, store.ErrRecordNotFound
+	}
+	copied := *w
+
+	return &copied, nil
+}
+
+// List ...
+func (r *WebhookRepository) List() ([]*model.Webhook, error) {
+	return r.filter(func(*model.Webhook) bool { return true }), nil
+}
+
+// FindByEvent ...
+func (r *WebhookRepository) FindByEvent(event string) ([]*model.Webhook, error) {
+	return r.filter(func(w *model.Webhook) bool { return w.Subscribes(event) }), nil
+}
+
+// Delete ...
+func (r *WebhookRepository) Delete(id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return store.ErrRecordNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.webhooks[oid]; !ok {
+		return store.ErrRecordNotFound
+	}
+	delete(r.webhooks, oid)
+
+	return nil
+}
+
+func (r *WebhookRepository) filter(keep func(*model.Webhook) bool) []*model.Webhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var webhooks []*model.Webhook
+	for _, w := range r.webhooks {
+		if keep(w) {
+			copied := *w
+			webhooks = append(webhooks, &copied)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].Created.Before(webhooks[j].Created)
+	})
+
+	return webhooks
+}
+
+// WebhookDeliveryRepository ...
+type WebhookDeliveryRepository struct {
+	store      *Store
+	mu         sync.Mutex
+	deliveries map[primitive.ObjectID]*model.WebhookDelivery
+}
+
+// Create ...
+func (r *WebhookDeliveryRepository) Create(d *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if d.ID.IsZero() {
+		d.ID = primitive.NewObjectID()
+	}
+	copied := *d
+	r.deliveries[d.ID] = &copied
+
+	return nil
+}
+
+// Claim ...
+func (r *WebhookDeliveryRepository) Claim(now time.Time, lease time.Duration) (*model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due *model.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttempt.After(now) &&
+			(due == nil || d.NextAttempt.Before(due.NextAttempt)) {
+			due = d
+		}
+	}
+	if due == nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	due.NextAttempt = now.Add(lease)
+	copied := *due
+
+	return &copied, nil
+}
+
+// Update ...
+func (r *WebhookDeliveryRepository) Update(d *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[d.ID]; !ok {
+		return store.ErrRecordNotFound
+	}
+	copied := *d
+	r.deliveries[d.ID] = &copied
+
+	return nil
+}
+
+// FindByWebhook ...
+func (r *WebhookDeliveryRepository) FindByWebhook(webhookID string, status string) ([]*model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deliveries []*model.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.WebhookID.Hex() == webhookID && d.Status == status {
+			copied := *d
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Created.Before(deliveries[j].Created)
+	})
+
+	return deliveries, nil
+}